@@ -0,0 +1,108 @@
+// Bulk computation of a form's counters with a single aggregation.
+
+package analytics
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// typeFacet names the $facet branch of a field type.
+func typeFacet(fieldType string) string { return "type_" + fieldType }
+
+// Aggregate computes the same counters Record maintains incrementally, over
+// the responses selected by match.
+func Aggregate(ctx context.Context, respCol *mongo.Collection, form models.Form, match bson.M) (models.FormAnalytics, error) {
+	counters := models.FormAnalytics{FormID: form.ID, Fields: map[string]models.FieldCounters{}}
+
+	total, err := respCol.CountDocuments(ctx, match)
+	if err != nil {
+		return counters, err
+	}
+	counters.Total = total
+
+	allIDs := bson.A{}
+	idsByType := map[string]bson.A{}
+	for _, f := range form.Fields {
+		allIDs = append(allIDs, f.ID)
+		if _, ok := aggregators[f.Type]; ok {
+			idsByType[f.Type] = append(idsByType[f.Type], f.ID)
+		}
+	}
+
+	facets := bson.M{
+		"answered": mongo.Pipeline{
+			{{Key: "$group", Value: bson.M{"_id": "$kv.k", "n": bson.M{"$sum": 1}}}},
+		},
+	}
+	for t, ids := range idsByType {
+		p := aggregators[t].Pipeline()
+		if p == nil {
+			continue
+		}
+		facets[typeFacet(t)] = append(mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"kv.k": bson.M{"$in": ids}}}},
+		}, p...)
+	}
+
+	// Unwind answers once, then let each facet pick the fields it owns.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.M{"kv": bson.M{"$objectToArray": "$answers"}}}},
+		{{Key: "$unwind", Value: "$kv"}},
+		{{Key: "$match", Value: bson.M{
+			"kv.k": bson.M{"$in": allIDs},
+			"kv.v": bson.M{"$nin": bson.A{nil, "", bson.A{}}},
+		}}},
+		{{Key: "$facet", Value: facets}},
+	}
+
+	cur, err := respCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return counters, err
+	}
+	var out []map[string][]bson.Raw
+	if err := cur.All(ctx, &out); err != nil {
+		return counters, err
+	}
+	if len(out) == 0 {
+		return counters, nil
+	}
+
+	working := map[string]*models.FieldCounters{}
+	field := func(id string) *models.FieldCounters {
+		fc, ok := working[id]
+		if !ok {
+			fc = &models.FieldCounters{}
+			working[id] = fc
+		}
+		return fc
+	}
+
+	for _, row := range out[0]["answered"] {
+		var r struct {
+			ID string `bson:"_id"`
+			N  int64  `bson:"n"`
+		}
+		if err := bson.Unmarshal(row, &r); err != nil {
+			return counters, err
+		}
+		field(r.ID).Answered = r.N
+	}
+	for t := range idsByType {
+		for _, row := range out[0][typeFacet(t)] {
+			if err := aggregators[t].Merge(row, field); err != nil {
+				return counters, err
+			}
+		}
+	}
+
+	for id, fc := range working {
+		counters.Fields[CounterKey(id)] = *fc
+	}
+	return counters, nil
+}
@@ -0,0 +1,172 @@
+// Per-field-type aggregators: each field type plugs in how it is computed in
+// bulk, updated per submission and rendered.
+
+package analytics
+
+import (
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// Aggregator summarizes the answers of one field type. Bulk (Pipeline/Merge)
+// and incremental (Increment) paths must produce the same counters.
+type Aggregator interface {
+	// Pipeline refines rows shaped {kv: {k, v}}, already limited to answered
+	// values of this type's fields, into rows Merge understands. A nil
+	// pipeline means the type only needs the shared answered count.
+	Pipeline() mongo.Pipeline
+	// Merge folds one row of Pipeline output into the counters; field returns
+	// the (mutable) counters of a field by ID.
+	Merge(row bson.Raw, field func(id string) *models.FieldCounters) error
+	// Increment records one answered value v.
+	Increment(u *FieldUpdate, v interface{})
+	// Render fills the type-specific part of res.
+	Render(f models.Field, fc models.FieldCounters, res *FieldResult)
+}
+
+var aggregators = map[string]Aggregator{
+	"rating":   ratingAggregator{},
+	"mc":       choiceAggregator{},
+	"checkbox": choiceAggregator{},
+	"text":     textAggregator{},
+}
+
+// Register installs the aggregator for a field type, replacing any existing
+// one. Call it during init, before serving requests.
+func Register(fieldType string, a Aggregator) {
+	aggregators[fieldType] = a
+}
+
+// choiceAggregator tallies selected options; checkbox arrays count each
+// selected option.
+type choiceAggregator struct{}
+
+func (choiceAggregator) Pipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$unwind", Value: "$kv.v"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"f": "$kv.k", "o": "$kv.v"},
+			"n":   bson.M{"$sum": 1},
+		}}},
+	}
+}
+
+func (choiceAggregator) Merge(row bson.Raw, field func(string) *models.FieldCounters) error {
+	var r struct {
+		ID struct {
+			F string      `bson:"f"`
+			O interface{} `bson:"o"`
+		} `bson:"_id"`
+		N int64 `bson:"n"`
+	}
+	if err := bson.Unmarshal(row, &r); err != nil {
+		return err
+	}
+	opt, ok := CategoryKey(r.ID.O)
+	if !ok {
+		return nil
+	}
+	fc := field(r.ID.F)
+	if fc.Options == nil {
+		fc.Options = map[string]int64{}
+	}
+	fc.Options[CounterKey(opt)] += r.N
+	return nil
+}
+
+func (choiceAggregator) Increment(u *FieldUpdate, v interface{}) {
+	// Repeated options in one answer are tallied once per occurrence, like
+	// the pipeline's $unwind.
+	tally := map[string]int64{}
+	for _, o := range AnswerStrings(v) {
+		tally[CounterKey(o)]++
+	}
+	for o, n := range tally {
+		u.Inc("options."+o, n)
+	}
+}
+
+func (choiceAggregator) Render(f models.Field, fc models.FieldCounters, res *FieldResult) {
+	counts := make(map[string]int64, len(fc.Options))
+	for k, n := range fc.Options {
+		counts[CounterKeyDecode(k)] = n
+	}
+	res.Options = optionCounts(f, counts, res.Answered)
+}
+
+// optionCounts lists every declared option (zero counts included) followed by
+// any unlisted values still present in older responses.
+func optionCounts(f models.Field, counts map[string]int64, answered int64) []OptionCount {
+	share := func(n int64) float64 {
+		if answered == 0 {
+			return 0
+		}
+		return float64(n) / float64(answered)
+	}
+	out := make([]OptionCount, 0, len(f.Options))
+	for _, o := range f.Options {
+		out = append(out, OptionCount{Option: o, Count: counts[o], Share: share(counts[o])})
+	}
+	var extra []string
+	for o := range counts {
+		if !ContainsString(f.Options, o) {
+			extra = append(extra, o)
+		}
+	}
+	SortCategories(extra)
+	for _, o := range extra {
+		out = append(out, OptionCount{Option: o, Count: counts[o], Share: share(counts[o]), Unlisted: true})
+	}
+	return out
+}
+
+// textAggregator tracks answer lengths in code points.
+type textAggregator struct{}
+
+func (textAggregator) Pipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"kv.v": bson.M{"$type": "string"}}}},
+		{{Key: "$project", Value: bson.M{"k": "$kv.k", "len": bson.M{"$strLenCP": "$kv.v"}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$k",
+			"sum":   bson.M{"$sum": "$len"},
+			"max":   bson.M{"$max": "$len"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+}
+
+func (textAggregator) Merge(row bson.Raw, field func(string) *models.FieldCounters) error {
+	var r struct {
+		ID    string `bson:"_id"`
+		Sum   int64  `bson:"sum"`
+		Max   int64  `bson:"max"`
+		Count int64  `bson:"count"`
+	}
+	if err := bson.Unmarshal(row, &r); err != nil {
+		return err
+	}
+	fc := field(r.ID)
+	fc.Count, fc.LenSum, fc.LenMax = r.Count, r.Sum, r.Max
+	return nil
+}
+
+func (textAggregator) Increment(u *FieldUpdate, v interface{}) {
+	if s, ok := v.(string); ok {
+		n := int64(utf8.RuneCountInString(s))
+		u.Inc("count", 1)
+		u.Inc("lenSum", n)
+		u.Max("lenMax", n)
+	}
+}
+
+func (textAggregator) Render(_ models.Field, fc models.FieldCounters, res *FieldResult) {
+	res.Text = &TextSummary{MaxLength: fc.LenMax}
+	if fc.Count > 0 {
+		res.Text.AvgLength = float64(fc.LenSum) / float64(fc.Count)
+	}
+}
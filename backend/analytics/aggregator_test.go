@@ -0,0 +1,284 @@
+package analytics
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+func intp(n int) *int { return &n }
+
+func floatp(v float64) *float64 { return &v }
+
+func row(t *testing.T, doc bson.M) bson.Raw {
+	t.Helper()
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// mergeRows runs rows through agg.Merge and returns the counters by field.
+func mergeRows(t *testing.T, agg Aggregator, rows ...bson.M) map[string]*models.FieldCounters {
+	t.Helper()
+	fields := map[string]*models.FieldCounters{}
+	field := func(id string) *models.FieldCounters {
+		if fields[id] == nil {
+			fields[id] = &models.FieldCounters{}
+		}
+		return fields[id]
+	}
+	for _, r := range rows {
+		if err := agg.Merge(row(t, r), field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fields
+}
+
+// increment runs agg.Increment for each value and returns the operators.
+func increment(agg Aggregator, values ...interface{}) (inc, lo, hi bson.M) {
+	inc, lo, hi = bson.M{}, bson.M{}, bson.M{}
+	for _, v := range values {
+		agg.Increment(&FieldUpdate{path: "fields.f.", inc: inc, lo: lo, hi: hi}, v)
+	}
+	return inc, lo, hi
+}
+
+func TestChoiceAggregator(t *testing.T) {
+	agg := choiceAggregator{}
+
+	t.Run("merge", func(t *testing.T) {
+		got := mergeRows(t, agg,
+			bson.M{"_id": bson.M{"f": "color", "o": "red"}, "n": int64(3)},
+			bson.M{"_id": bson.M{"f": "color", "o": "a.b"}, "n": int64(1)},
+			bson.M{"_id": bson.M{"f": "color", "o": "red"}, "n": int64(2)},
+			bson.M{"_id": bson.M{"f": "size", "o": int32(4)}, "n": int64(1)},
+			bson.M{"_id": bson.M{"f": "size", "o": true}, "n": int64(9)},
+		)
+		want := map[string]int64{"red": 5, "a%2Eb": 1}
+		if !reflect.DeepEqual(got["color"].Options, want) {
+			t.Errorf("color options = %v, want %v", got["color"].Options, want)
+		}
+		if !reflect.DeepEqual(got["size"].Options, map[string]int64{"4": 1}) {
+			t.Errorf("size options = %v, want only the numeric value", got["size"].Options)
+		}
+	})
+
+	t.Run("increment", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			value interface{}
+			want  bson.M
+		}{
+			{"single", "red", bson.M{"fields.f.options.red": int64(1)}},
+			{"checkbox", []interface{}{"a", "b$"}, bson.M{"fields.f.options.a": int64(1), "fields.f.options.b%24": int64(1)}},
+			{"repeated", []interface{}{"a", "a"}, bson.M{"fields.f.options.a": int64(2)}},
+		}
+		for _, tt := range tests {
+			inc, _, _ := increment(agg, tt.value)
+			if !reflect.DeepEqual(inc, tt.want) {
+				t.Errorf("%s: inc = %v, want %v", tt.name, inc, tt.want)
+			}
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		f := models.Field{ID: "color", Type: "mc", Options: []string{"red", "blue"}}
+		var res FieldResult
+		res.Answered = 4
+		agg.Render(f, models.FieldCounters{Options: map[string]int64{"red": 3, "old%2Eone": 1}}, &res)
+		want := []OptionCount{
+			{Option: "red", Count: 3, Share: 0.75},
+			{Option: "blue", Count: 0, Share: 0},
+			{Option: "old.one", Count: 1, Share: 0.25, Unlisted: true},
+		}
+		if !reflect.DeepEqual(res.Options, want) {
+			t.Errorf("options = %+v, want %+v", res.Options, want)
+		}
+	})
+}
+
+func TestTextAggregator(t *testing.T) {
+	agg := textAggregator{}
+
+	got := mergeRows(t, agg, bson.M{"_id": "note", "sum": int64(12), "max": int64(7), "count": int64(3)})
+	if fc := got["note"]; fc.Count != 3 || fc.LenSum != 12 || fc.LenMax != 7 {
+		t.Errorf("merge = %+v", fc)
+	}
+
+	inc, _, hi := increment(agg, "héllo", 42)
+	if !reflect.DeepEqual(inc, bson.M{"fields.f.count": 1, "fields.f.lenSum": int64(5)}) {
+		t.Errorf("inc = %v (lengths are in code points; non-strings ignored)", inc)
+	}
+	if !reflect.DeepEqual(hi, bson.M{"fields.f.lenMax": int64(5)}) {
+		t.Errorf("max = %v", hi)
+	}
+
+	tests := []struct {
+		fc   models.FieldCounters
+		want TextSummary
+	}{
+		{models.FieldCounters{}, TextSummary{}},
+		{models.FieldCounters{Count: 4, LenSum: 10, LenMax: 6}, TextSummary{AvgLength: 2.5, MaxLength: 6}},
+	}
+	for _, tt := range tests {
+		var res FieldResult
+		agg.Render(models.Field{}, tt.fc, &res)
+		if *res.Text != tt.want {
+			t.Errorf("render %+v = %+v, want %+v", tt.fc, *res.Text, tt.want)
+		}
+	}
+}
+
+func TestRatingAggregator(t *testing.T) {
+	agg := ratingAggregator{}
+
+	t.Run("merge", func(t *testing.T) {
+		got := mergeRows(t, agg,
+			bson.M{"_id": bson.M{"f": "score", "v": int32(4)}, "n": int64(2)},
+			bson.M{"_id": bson.M{"f": "score", "v": 2.5}, "n": int64(1)},
+			bson.M{"_id": bson.M{"f": "score", "v": "x"}, "n": int64(5)},
+		)
+		fc := got["score"]
+		if fc.Count != 3 || fc.Sum != 10.5 || fc.SumSq != 38.25 {
+			t.Errorf("moments = count %d sum %v sumSq %v", fc.Count, fc.Sum, fc.SumSq)
+		}
+		if *fc.Min != 2.5 || *fc.Max != 4 {
+			t.Errorf("min/max = %v/%v", *fc.Min, *fc.Max)
+		}
+		if !reflect.DeepEqual(fc.Options, map[string]int64{"4": 2, "2%2E5": 1}) {
+			t.Errorf("options = %v", fc.Options)
+		}
+	})
+
+	t.Run("increment", func(t *testing.T) {
+		inc, lo, hi := increment(agg, 3.0)
+		want := bson.M{"fields.f.count": 1, "fields.f.sum": 3.0, "fields.f.sumSq": 9.0, "fields.f.options.3": 1}
+		if !reflect.DeepEqual(inc, want) {
+			t.Errorf("inc = %v, want %v", inc, want)
+		}
+		if lo["fields.f.min"] != 3.0 || hi["fields.f.max"] != 3.0 {
+			t.Errorf("min/max = %v/%v", lo, hi)
+		}
+		if inc, _, _ := increment(agg, "3"); len(inc) != 0 {
+			t.Errorf("non-numeric answer incremented %v", inc)
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		f := models.Field{ID: "score", Type: "rating", Min: intp(1), Max: intp(5)}
+		// answers 1, 2, 2, 3, 5
+		fc := models.FieldCounters{
+			Count: 5, Sum: 13, SumSq: 43, Min: floatp(1), Max: floatp(5),
+			Options: map[string]int64{"1": 1, "2": 2, "3": 1, "5": 1},
+		}
+		var res FieldResult
+		agg.Render(f, fc, &res)
+		s := res.Rating
+		checks := []struct {
+			name      string
+			got, want float64
+		}{
+			{"avg", *s.Avg, 2.6},
+			{"median", *s.Median, 2},
+			{"p25", *s.P25, 2},
+			{"p75", *s.P75, 3},
+			{"p90", *s.P90, 4.2},
+			{"stdDev", *s.StdDev, math.Sqrt(2.3)},
+			{"ci lower", s.MeanCI.Lower, 2.6 - 2.776*math.Sqrt(2.3)/math.Sqrt(5)},
+		}
+		for _, c := range checks {
+			if math.Abs(c.got-c.want) > 1e-9 {
+				t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+			}
+		}
+		if len(s.Distribution) != 5 || s.Distribution[3] != (RatingBucket{Value: 4}) {
+			t.Errorf("distribution = %+v, want every step 1..5", s.Distribution)
+		}
+	})
+
+	t.Run("render without answers", func(t *testing.T) {
+		var res FieldResult
+		agg.Render(models.Field{Min: intp(1), Max: intp(3)}, models.FieldCounters{}, &res)
+		if res.Rating.Avg != nil || res.Rating.StdDev != nil || len(res.Rating.Distribution) != 3 {
+			t.Errorf("empty summary = %+v", res.Rating)
+		}
+	})
+
+	t.Run("wide legacy scale", func(t *testing.T) {
+		var res FieldResult
+		fc := models.FieldCounters{Count: 1, Sum: 7, SumSq: 49, Options: map[string]int64{"7": 1}}
+		agg.Render(models.Field{Min: intp(0), Max: intp(2000000000)}, fc, &res)
+		if len(res.Rating.Distribution) != 1 {
+			t.Errorf("distribution has %d buckets, want observed values only", len(res.Rating.Distribution))
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	form := models.Form{Fields: []models.Field{
+		{ID: "q.1", Label: "Color", Type: "mc", Required: true, Options: []string{"red", "blue"}},
+		{ID: "score", Label: "Score", Type: "rating", Min: intp(1), Max: intp(3)},
+		{ID: "note", Label: "Note", Type: "text"},
+		{ID: "custom", Label: "Custom", Type: "date"},
+	}}
+	counters := models.FormAnalytics{
+		Total: 4,
+		Fields: map[string]models.FieldCounters{
+			"q%2E1": {Answered: 4, Options: map[string]int64{"red": 3, "blue": 1}},
+			"score": {Answered: 2, Count: 2, Sum: 4, SumSq: 8, Options: map[string]int64{"2": 2}},
+			"note":  {Answered: 1, Count: 1, LenSum: 5, LenMax: 5},
+		},
+	}
+
+	res := Render(form, counters)
+
+	if res.TotalResponses != 4 {
+		t.Errorf("total = %d", res.TotalResponses)
+	}
+	if !reflect.DeepEqual(res.FieldOrder, []string{"q.1", "score", "note", "custom"}) {
+		t.Errorf("order = %v", res.FieldOrder)
+	}
+	if len(res.Fields) != 3 || len(res.Text) != 1 {
+		t.Fatalf("fields %d text %d, want 3 and 1", len(res.Fields), len(res.Text))
+	}
+
+	tests := []struct {
+		id                string
+		answered, skipped int64
+		rate              float64
+	}{
+		{"q.1", 4, 0, 1},
+		{"score", 2, 2, 0.5},
+		{"custom", 0, 4, 0},
+	}
+	for _, tt := range tests {
+		fr := res.Fields[tt.id]
+		if fr.Answered != tt.answered || fr.Skipped != tt.skipped || fr.ResponseRate != tt.rate {
+			t.Errorf("%s: answered %d skipped %d rate %v", tt.id, fr.Answered, fr.Skipped, fr.ResponseRate)
+		}
+	}
+	if !res.Fields["q.1"].Required || res.Fields["q.1"].Options[0].Count != 3 {
+		t.Errorf("choice field = %+v", res.Fields["q.1"])
+	}
+	if r := res.Fields["score"].Rating; r == nil || *r.Avg != 2 {
+		t.Errorf("rating = %+v", r)
+	}
+	if fr := res.Fields["custom"]; fr.Rating != nil || fr.Options != nil || fr.Text != nil {
+		t.Errorf("type without aggregator rendered %+v", fr)
+	}
+	if tx := res.Text["note"].Text; tx == nil || tx.AvgLength != 5 {
+		t.Errorf("text = %+v", tx)
+	}
+
+	empty := Render(form, models.FormAnalytics{})
+	if empty.Fields["q.1"].ResponseRate != 0 || empty.Fields["q.1"].Skipped != 0 {
+		t.Errorf("empty render = %+v", empty.Fields["q.1"])
+	}
+}
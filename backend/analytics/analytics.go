@@ -0,0 +1,217 @@
+// Package analytics computes form analytics from the form definition: bulk
+// aggregation, incrementally maintained counters and a typed result shared
+// by the REST handler, the SSE stream and exports.
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// Result is the analytics payload for a form. Choice/rating fields are keyed
+// by ID under Fields, text fields under Text; FieldOrder preserves the
+// form's order.
+type Result struct {
+	TotalResponses int64                  `json:"totalResponses"`
+	FieldOrder     []string               `json:"fieldOrder"`
+	Fields         map[string]FieldResult `json:"fields"`
+	Text           map[string]FieldResult `json:"text"`
+}
+
+// FieldResult is the summary of one field over the selected responses.
+// Which of Rating, Options or Text is set depends on Type.
+type FieldResult struct {
+	ID           string         `json:"id"`
+	Label        string         `json:"label"`
+	Type         string         `json:"type"`
+	Required     bool           `json:"required"`
+	Answered     int64          `json:"answered"`
+	Skipped      int64          `json:"skipped"`
+	ResponseRate float64        `json:"responseRate"`
+	Rating       *RatingSummary `json:"rating,omitempty"`
+	Options      []OptionCount  `json:"options,omitempty"`
+	Text         *TextSummary   `json:"text,omitempty"`
+}
+
+// RatingSummary describes numeric answers. Percentiles interpolate linearly
+// between ranked values; StdDev is the sample standard deviation. Statistics
+// that need more answers than available are nil.
+type RatingSummary struct {
+	Count        int64               `json:"count"`
+	Avg          *float64            `json:"avg"`
+	Min          *float64            `json:"min"`
+	Max          *float64            `json:"max"`
+	Median       *float64            `json:"median"`
+	P25          *float64            `json:"p25"`
+	P75          *float64            `json:"p75"`
+	P90          *float64            `json:"p90"`
+	StdDev       *float64            `json:"stdDev"`
+	MeanCI       *ConfidenceInterval `json:"meanCI"`
+	Distribution []RatingBucket      `json:"distribution"`
+}
+
+// ConfidenceInterval is a two-sided interval for the mean.
+type ConfidenceInterval struct {
+	Level float64 `json:"level"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// RatingBucket is the number of answers with one value. Every step of the
+// field's Min..Max scale is listed, including unused ones.
+type RatingBucket struct {
+	Value float64 `json:"value"`
+	Count int64   `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// OptionCount is one option's tally. Share is relative to the responses that
+// answered the field, so checkbox shares may sum past 1. Unlisted marks
+// values no longer among the field's options.
+type OptionCount struct {
+	Option   string  `json:"option"`
+	Count    int64   `json:"count"`
+	Share    float64 `json:"share"`
+	Unlisted bool    `json:"unlisted,omitempty"`
+}
+
+type TextSummary struct {
+	AvgLength float64 `json:"avgLength"`
+	MaxLength int64   `json:"maxLength"`
+}
+
+// Render turns counters into the result for form.
+func Render(form models.Form, counters models.FormAnalytics) Result {
+	total := counters.Total
+	res := Result{
+		TotalResponses: total,
+		FieldOrder:     make([]string, 0, len(form.Fields)),
+		Fields:         map[string]FieldResult{},
+		Text:           map[string]FieldResult{},
+	}
+	for _, f := range form.Fields {
+		fc := counters.Fields[CounterKey(f.ID)]
+		fr := FieldResult{
+			ID:       f.ID,
+			Label:    f.Label,
+			Type:     f.Type,
+			Required: f.Required,
+			Answered: fc.Answered,
+			Skipped:  total - fc.Answered,
+		}
+		if total > 0 {
+			fr.ResponseRate = float64(fr.Answered) / float64(total)
+		}
+		if agg, ok := aggregators[f.Type]; ok {
+			agg.Render(f, fc, &fr)
+		}
+
+		res.FieldOrder = append(res.FieldOrder, f.ID)
+		if fr.Text != nil {
+			res.Text[f.ID] = fr
+		} else {
+			res.Fields[f.ID] = fr
+		}
+	}
+	return res
+}
+
+// Compute aggregates form over the responses selected by match.
+func Compute(ctx context.Context, respCol *mongo.Collection, form models.Form, match bson.M) (Result, error) {
+	counters, err := Aggregate(ctx, respCol, form, match)
+	if err != nil {
+		return Result{}, err
+	}
+	return Render(form, counters), nil
+}
+
+// Snapshot serves unfiltered analytics from the stored counters and filtered
+// ones (a match narrower than all live responses) from a fresh aggregation.
+func Snapshot(ctx context.Context, db *mongo.Database, form models.Form, match bson.M, filtered bool) (Result, error) {
+	respCol := db.Collection("responses")
+	if filtered {
+		return Compute(ctx, respCol, form, match)
+	}
+	counters, err := Load(ctx, db.Collection(Collection), respCol, form)
+	if err != nil {
+		return Result{}, err
+	}
+	return Render(form, counters), nil
+}
+
+// CategoryKey renders an answer value as a category label; numbers become
+// their decimal representation.
+func CategoryKey(v interface{}) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	if n, ok := ToFloat(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// SortCategories orders numeric categories by value and the rest lexically.
+func SortCategories(cats []string) {
+	sort.Slice(cats, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(cats[i], 64)
+		b, errB := strconv.ParseFloat(cats[j], 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return cats[i] < cats[j]
+	})
+}
+
+// toFloat converts the numeric BSON types an answer may decode to.
+func ToFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// answerStrings flattens a scalar or array answer to strings.
+func AnswerStrings(v interface{}) []string {
+	var arr []interface{}
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case primitive.A:
+		arr = t
+	case []interface{}:
+		arr = t
+	default:
+		return []string{fmt.Sprint(t)}
+	}
+	out := make([]string, 0, len(arr))
+	for _, x := range arr {
+		out = append(out, fmt.Sprint(x))
+	}
+	return out
+}
+
+// ContainsString reports whether s is in ss.
+func ContainsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
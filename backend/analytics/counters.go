@@ -0,0 +1,253 @@
+// Incrementally maintained per-form counters: updated atomically on every
+// submission, rebuilt from responses when missing, stale or drifted.
+
+package analytics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// Collection holds one models.FormAnalytics document per form.
+const Collection = "form_analytics"
+
+// schemaVersion is bumped whenever the counters layout changes, so stored
+// documents written by an older layout are rebuilt on read.
+const schemaVersion = 2
+
+// rebuildAttempts bounds retries when submissions keep landing mid-rebuild.
+const rebuildAttempts = 3
+
+// pendingTimeout is how long a Begin without its Record (a crashed or failed
+// submission) holds off rebuilds.
+const pendingTimeout = time.Minute
+
+// rebuildPendingWait is the pause before retrying past a pending submission.
+const rebuildPendingWait = 50 * time.Millisecond
+
+var (
+	counterKeyEscaper   = strings.NewReplacer("%", "%25", ".", "%2E", "$", "%24")
+	counterKeyUnescaper = strings.NewReplacer("%2E", ".", "%24", "$", "%25", "%")
+)
+
+// CounterKey escapes a field ID or option so it is safe as a document key
+// and inside a dotted update path.
+func CounterKey(s string) string { return counterKeyEscaper.Replace(s) }
+
+func CounterKeyDecode(s string) string { return counterKeyUnescaper.Replace(s) }
+
+// Answered mirrors the aggregation's notion of an answer: nil, "" and []
+// count as skipped.
+func Answered(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case string:
+		return t != ""
+	case []interface{}:
+		return len(t) > 0
+	case primitive.A:
+		return len(t) > 0
+	}
+	return true
+}
+
+// FieldUpdate collects the update operators for one field's counters.
+type FieldUpdate struct {
+	path        string
+	inc, lo, hi bson.M
+}
+
+func (u *FieldUpdate) Inc(key string, n interface{}) { u.inc[u.path+key] = n }
+func (u *FieldUpdate) Min(key string, v interface{}) { u.lo[u.path+key] = v }
+func (u *FieldUpdate) Max(key string, v interface{}) { u.hi[u.path+key] = v }
+
+// SubmissionUpdate builds the atomic update recording one response.
+func SubmissionUpdate(fields []models.Field, answers map[string]interface{}, now time.Time) bson.M {
+	inc := bson.M{"total": 1, "seq": 1, "pending": -1}
+	lo, hi := bson.M{}, bson.M{}
+
+	for _, f := range fields {
+		v, ok := answers[f.ID]
+		if !ok || !Answered(v) {
+			continue
+		}
+		u := &FieldUpdate{path: "fields." + CounterKey(f.ID) + ".", inc: inc, lo: lo, hi: hi}
+		u.Inc("answered", 1)
+		if agg, ok := aggregators[f.Type]; ok {
+			agg.Increment(u, v)
+		}
+	}
+
+	update := bson.M{"$inc": inc, "$set": bson.M{"updatedAt": now}}
+	if len(lo) > 0 {
+		update["$min"] = lo
+	}
+	if len(hi) > 0 {
+		update["$max"] = hi
+	}
+	return update
+}
+
+// Begin announces a submission before its response is inserted. It bumps seq
+// so a rebuild already in flight won't store its result, and marks the
+// submission pending so one starting before Record lands won't either:
+// either aggregation may or may not see the response, while Record adds it
+// regardless. Every Begin must be followed by Record or Abort.
+func Begin(ctx context.Context, col *mongo.Collection, formID primitive.ObjectID) error {
+	_, err := col.UpdateOne(ctx,
+		bson.M{"_id": formID},
+		bson.M{"$inc": bson.M{"seq": 1, "pending": 1}, "$set": bson.M{"pendingAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Abort ends a Begin whose submission won't be recorded.
+func Abort(ctx context.Context, col *mongo.Collection, formID primitive.ObjectID) {
+	if _, err := col.UpdateOne(ctx,
+		bson.M{"_id": formID},
+		bson.M{"$inc": bson.M{"seq": 1, "pending": -1}},
+	); err != nil {
+		log.Printf("analytics: abort submission for form %s failed: %v", formID.Hex(), err)
+	}
+}
+
+// Record folds one response, announced by Begin, into the form's counters.
+// A missing document is upserted incomplete and rebuilt on the next read.
+func Record(ctx context.Context, col *mongo.Collection, formID primitive.ObjectID, fields []models.Field, answers map[string]interface{}) error {
+	_, err := col.UpdateOne(ctx,
+		bson.M{"_id": formID},
+		SubmissionUpdate(fields, answers, time.Now()),
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// MarkStale forces a rebuild on the next read, for changes that can't be
+// applied incrementally (deletes, restores, field edits). Bumping seq also
+// invalidates any rebuild already in flight.
+func MarkStale(ctx context.Context, col *mongo.Collection, formID primitive.ObjectID) {
+	if _, err := col.UpdateOne(ctx,
+		bson.M{"_id": formID},
+		bson.M{"$set": bson.M{"stale": true}, "$inc": bson.M{"seq": 1}},
+	); err != nil {
+		log.Printf("analytics: mark form %s stale failed: %v", formID.Hex(), err)
+	}
+}
+
+// Rebuild recomputes a form's counters from its live responses. The write
+// only succeeds if seq is unchanged since the rebuild started and no
+// submission was pending, so concurrent submissions are neither overwritten
+// nor counted twice.
+func Rebuild(ctx context.Context, col, respCol *mongo.Collection, form models.Form) (models.FormAnalytics, error) {
+	match := bson.M{"formId": form.ID, "deletedAt": nil}
+	for attempt := 0; attempt < rebuildAttempts; attempt++ {
+		var prev struct {
+			Seq       int64      `bson:"seq"`
+			Pending   int64      `bson:"pending"`
+			PendingAt *time.Time `bson:"pendingAt"`
+		}
+		err := col.FindOne(ctx, bson.M{"_id": form.ID},
+			options.FindOne().SetProjection(bson.M{"seq": 1, "pending": 1, "pendingAt": 1})).Decode(&prev)
+		exists := err == nil
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return models.FormAnalytics{}, err
+		}
+		if prev.Pending > 0 && prev.PendingAt != nil && time.Since(*prev.PendingAt) < pendingTimeout {
+			// A submission is between insert and Record.
+			time.Sleep(rebuildPendingWait)
+			continue
+		}
+
+		counters, err := Aggregate(ctx, respCol, form, match)
+		if err != nil {
+			return counters, err
+		}
+		counters.Seq = prev.Seq
+		counters.Complete = true
+		counters.Schema = schemaVersion
+		counters.UpdatedAt = time.Now()
+
+		if !exists {
+			if _, err := col.InsertOne(ctx, counters); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					continue
+				}
+				return counters, err
+			}
+			return counters, nil
+		}
+		res, err := col.ReplaceOne(ctx, bson.M{"_id": form.ID, "seq": prev.Seq}, counters)
+		if err != nil {
+			return counters, err
+		}
+		if res.MatchedCount == 1 {
+			return counters, nil
+		}
+	}
+	// Still racing with writers: serve a fresh aggregation and leave the
+	// stored counters for the next read to rebuild.
+	return Aggregate(ctx, respCol, form, match)
+}
+
+// Load returns the stored counters for form, rebuilding them first when they
+// are missing, incomplete, stale or from an older schema.
+func Load(ctx context.Context, col, respCol *mongo.Collection, form models.Form) (models.FormAnalytics, error) {
+	var counters models.FormAnalytics
+	err := col.FindOne(ctx, bson.M{"_id": form.ID}).Decode(&counters)
+	if err == nil && counters.Complete && !counters.Stale && counters.Schema == schemaVersion {
+		return counters, nil
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return counters, err
+	}
+	return Rebuild(ctx, col, respCol, form)
+}
+
+// RebuildAll recomputes the counters of the given forms, or of every live
+// form when ids is empty. Used for backfills from the command line.
+func RebuildAll(ctx context.Context, db *mongo.Database, ids []string) error {
+	filter := bson.M{"deletedAt": nil}
+	if len(ids) > 0 {
+		oids := make([]primitive.ObjectID, 0, len(ids))
+		for _, id := range ids {
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return errors.New("invalid form id: " + id)
+			}
+			oids = append(oids, oid)
+		}
+		filter["_id"] = bson.M{"$in": oids}
+	}
+
+	cur, err := db.Collection("forms").Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	col, respCol := db.Collection(Collection), db.Collection("responses")
+	for cur.Next(ctx) {
+		var form models.Form
+		if err := cur.Decode(&form); err != nil {
+			return err
+		}
+		counters, err := Rebuild(ctx, col, respCol, form)
+		if err != nil {
+			return err
+		}
+		log.Printf("analytics: rebuilt form %s (%d responses)", form.ID.Hex(), counters.Total)
+	}
+	return cur.Err()
+}
@@ -0,0 +1,191 @@
+// Rating aggregation: running moments plus a value distribution, from which
+// percentiles, spread and a confidence interval for the mean are derived.
+
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// meanCILevel is the confidence level of RatingSummary.MeanCI.
+const meanCILevel = 0.95
+
+// tCritical95 holds two-sided 95% Student-t critical values for 1..30
+// degrees of freedom.
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tCritical returns the 95% critical value for df degrees of freedom,
+// stepping down the standard table beyond 30.
+func tCritical(df int64) float64 {
+	switch {
+	case df <= 30:
+		return tCritical95[df-1]
+	case df <= 40:
+		return 2.021
+	case df <= 60:
+		return 2.000
+	case df <= 120:
+		return 1.980
+	}
+	return 1.960
+}
+
+// ratingAggregator keeps count, sum, sum of squares, min, max and a tally
+// per distinct value of numeric answers.
+type ratingAggregator struct{}
+
+func (ratingAggregator) Pipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"kv.v": bson.M{"$type": "number"}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"f": "$kv.k", "v": "$kv.v"},
+			"n":   bson.M{"$sum": 1},
+		}}},
+	}
+}
+
+func (ratingAggregator) Merge(row bson.Raw, field func(string) *models.FieldCounters) error {
+	var r struct {
+		ID struct {
+			F string      `bson:"f"`
+			V interface{} `bson:"v"`
+		} `bson:"_id"`
+		N int64 `bson:"n"`
+	}
+	if err := bson.Unmarshal(row, &r); err != nil {
+		return err
+	}
+	v, ok := ToFloat(r.ID.V)
+	if !ok {
+		return nil
+	}
+	fc := field(r.ID.F)
+	fc.Count += r.N
+	fc.Sum += v * float64(r.N)
+	fc.SumSq += v * v * float64(r.N)
+	if fc.Min == nil || v < *fc.Min {
+		lo := v
+		fc.Min = &lo
+	}
+	if fc.Max == nil || v > *fc.Max {
+		hi := v
+		fc.Max = &hi
+	}
+	if fc.Options == nil {
+		fc.Options = map[string]int64{}
+	}
+	fc.Options[CounterKey(valueKey(v))] += r.N
+	return nil
+}
+
+func (ratingAggregator) Increment(u *FieldUpdate, v interface{}) {
+	if n, ok := ToFloat(v); ok {
+		u.Inc("count", 1)
+		u.Inc("sum", n)
+		u.Inc("sumSq", n*n)
+		u.Inc("options."+CounterKey(valueKey(n)), 1)
+		u.Min("min", n)
+		u.Max("max", n)
+	}
+}
+
+func valueKey(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+func (ratingAggregator) Render(f models.Field, fc models.FieldCounters, res *FieldResult) {
+	res.Rating = ratingSummary(f, fc)
+}
+
+func ratingSummary(f models.Field, fc models.FieldCounters) *RatingSummary {
+	s := &RatingSummary{Count: fc.Count, Min: fc.Min, Max: fc.Max}
+	dist := ratingDistribution(f, fc)
+	s.Distribution = dist
+	if fc.Count == 0 {
+		return s
+	}
+
+	n := float64(fc.Count)
+	mean := fc.Sum / n
+	s.Avg = &mean
+	s.Median = percentile(dist, fc.Count, 0.5)
+	s.P25 = percentile(dist, fc.Count, 0.25)
+	s.P75 = percentile(dist, fc.Count, 0.75)
+	s.P90 = percentile(dist, fc.Count, 0.9)
+
+	if fc.Count < 2 {
+		return s
+	}
+	variance := (fc.SumSq - fc.Sum*fc.Sum/n) / (n - 1)
+	if variance < 0 {
+		variance = 0 // float rounding on constant data
+	}
+	sd := math.Sqrt(variance)
+	s.StdDev = &sd
+	margin := tCritical(fc.Count-1) * sd / math.Sqrt(n)
+	s.MeanCI = &ConfidenceInterval{Level: meanCILevel, Lower: mean - margin, Upper: mean + margin}
+	return s
+}
+
+// MaxRatingSpan is the widest min..max scale a rating field may have; the
+// distribution lists every step of it.
+const MaxRatingSpan = 100
+
+// ratingDistribution lists every integer step of the field's scale, then any
+// other observed values, in ascending order. Scales wider than MaxRatingSpan
+// (saved before the limit) list observed values only.
+func ratingDistribution(f models.Field, fc models.FieldCounters) []RatingBucket {
+	counts := map[float64]int64{}
+	for k, c := range fc.Options {
+		if v, err := strconv.ParseFloat(CounterKeyDecode(k), 64); err == nil {
+			counts[v] += c
+		}
+	}
+	if f.Min != nil && f.Max != nil && int64(*f.Max)-int64(*f.Min) <= MaxRatingSpan {
+		for v := *f.Min; v <= *f.Max; v++ {
+			counts[float64(v)] += 0
+		}
+	}
+
+	out := make([]RatingBucket, 0, len(counts))
+	for v, c := range counts {
+		b := RatingBucket{Value: v, Count: c}
+		if fc.Count > 0 {
+			b.Share = float64(c) / float64(fc.Count)
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value < out[j].Value })
+	return out
+}
+
+// percentile interpolates linearly between the ranked values around
+// (n-1)*p, reading ranks off the sorted distribution.
+func percentile(dist []RatingBucket, n int64, p float64) *float64 {
+	valueAt := func(rank int64) float64 {
+		var seen int64
+		for _, b := range dist {
+			seen += b.Count
+			if rank < seen {
+				return b.Value
+			}
+		}
+		return dist[len(dist)-1].Value
+	}
+	h := float64(n-1) * p
+	lo := int64(math.Floor(h))
+	v := valueAt(lo)
+	if frac := h - float64(lo); frac > 0 {
+		v += frac * (valueAt(lo+1) - v)
+	}
+	return &v
+}
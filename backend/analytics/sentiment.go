@@ -0,0 +1,115 @@
+// Offline lexicon-based sentiment scoring for text answers.
+
+package analytics
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Sentiment of one answer. Score is the sum of word valences (-5..+5 each);
+// Comparative normalizes it by token count so long answers don't dominate.
+type Sentiment struct {
+	Score       float64 `json:"score"`
+	Comparative float64 `json:"comparative"`
+	Label       string  `json:"label"` // positive, negative or neutral
+}
+
+// neutralBand is the |comparative| below which an answer counts as neutral.
+const neutralBand = 0.05
+
+// negationWindow is how many preceding tokens a negator reaches.
+const negationWindow = 3
+
+// lexicons map lowercase words to valences in AFINN style.
+var lexicons = map[string]map[string]float64{
+	"en": valenceSet(`
+		amazing:4 awesome:4 excellent:3 fantastic:4 great:3 good:3 nice:3 love:3 loved:3 loves:3 like:2 liked:2
+		enjoy:2 enjoyed:2 happy:3 glad:3 pleased:3 satisfied:2 helpful:2 useful:2 easy:1 simple:1 clear:1 fast:2
+		quick:2 smooth:2 reliable:2 friendly:2 beautiful:3 perfect:3 best:3 better:2 wonderful:4 brilliant:4
+		impressive:3 recommend:2 recommended:2 thanks:2 thank:2 fun:3 cool:1 intuitive:2 efficient:2 responsive:2
+		polite:2 kind:2 fair:2 comfortable:2 improved:2 improvement:2 success:2 successful:3 win:4 works:1
+		worth:2 valuable:2 positive:2 solid:2 superb:5 outstanding:5 delighted:3 exciting:3 excited:3 convenient:2
+		bad:-3 terrible:-3 awful:-3 horrible:-3 poor:-2 worse:-3 worst:-3 hate:-3 hated:-3 dislike:-2 disliked:-2
+		slow:-2 broken:-1 bug:-2 bugs:-2 buggy:-2 crash:-2 crashes:-2 crashed:-2 error:-2 errors:-2 fail:-2
+		failed:-2 fails:-2 failure:-2 problem:-2 problems:-2 issue:-1 issues:-1 difficult:-1 hard:-1 confusing:-2
+		confused:-2 annoying:-2 annoyed:-2 frustrating:-2 frustrated:-2 disappointed:-2 disappointing:-2 useless:-2
+		unhelpful:-2 rude:-2 expensive:-1 overpriced:-2 boring:-3 ugly:-3 sad:-2 unhappy:-2 angry:-3 upset:-2
+		waste:-1 wasted:-2 lacking:-2 missing:-2 complicated:-2 unreliable:-2 unusable:-3 painful:-2 mess:-2
+		negative:-2 nightmare:-3 pathetic:-2 disaster:-2 stuck:-2 worried:-3 concern:-1 concerns:-1 complaint:-2
+		lag:-1 laggy:-2 clunky:-2 outdated:-2`),
+	"es": valenceSet(`
+		bueno:3 buena:3 excelente:3 genial:3 increíble:4 fantástico:4 perfecto:3 feliz:3 fácil:1 rápido:2
+		útil:2 encanta:3 gusta:2 mejor:2 recomiendo:2 gracias:2 bien:2
+		malo:-3 mala:-3 terrible:-3 horrible:-3 lento:-2 difícil:-1 problema:-2 problemas:-2 error:-2
+		peor:-3 odio:-3 triste:-2 inútil:-2 caro:-1 confuso:-2 aburrido:-3 mal:-2`),
+	"fr": valenceSet(`
+		bon:3 bonne:3 excellent:3 génial:3 super:3 parfait:3 heureux:3 facile:1 rapide:2 utile:2 aime:2
+		adore:3 meilleur:2 recommande:2 merci:2 bien:2 incroyable:4
+		mauvais:-3 mauvaise:-3 terrible:-3 horrible:-3 lent:-2 difficile:-1 problème:-2 problèmes:-2 erreur:-2
+		pire:-3 déteste:-3 triste:-2 inutile:-2 cher:-1 compliqué:-2 ennuyeux:-3 nul:-3`),
+	"de": valenceSet(`
+		gut:3 gute:3 toll:3 super:3 ausgezeichnet:3 perfekt:3 glücklich:3 einfach:1 schnell:2 hilfreich:2
+		nützlich:2 liebe:3 mag:2 besser:2 beste:3 empfehle:2 danke:2 großartig:4
+		schlecht:-3 schlechte:-3 schrecklich:-3 furchtbar:-3 langsam:-2 schwierig:-1 problem:-2 probleme:-2
+		fehler:-2 schlimmer:-3 hasse:-3 traurig:-2 nutzlos:-2 teuer:-1 kompliziert:-2 langweilig:-3`),
+}
+
+var negators = map[string]map[string]bool{
+	"en": wordSet(`not no never none nobody nothing neither nor without isn't wasn't aren't weren't don't doesn't
+		didn't can't cannot couldn't won't wouldn't shouldn't hardly barely`),
+	"es": wordSet(`no nunca jamás nada ninguno ninguna sin tampoco`),
+	"fr": wordSet(`ne pas jamais rien aucun aucune sans plus`),
+	"de": wordSet(`nicht kein keine keinen nie niemals nichts ohne`),
+}
+
+func valenceSet(list string) map[string]float64 {
+	out := map[string]float64{}
+	for _, pair := range strings.Fields(list) {
+		i := strings.LastIndexByte(pair, ':')
+		v, err := strconv.ParseFloat(pair[i+1:], 64)
+		if i <= 0 || err != nil {
+			panic("analytics: bad lexicon entry " + pair)
+		}
+		out[pair[:i]] = v
+	}
+	return out
+}
+
+// HasLexicon reports whether sentiment scoring is available for lang.
+func HasLexicon(lang string) bool {
+	_, ok := lexicons[lang]
+	return ok
+}
+
+// ScoreSentiment scores tokens (from Tokenize) against lang's lexicon. A
+// negator within the preceding few tokens flips a word's valence.
+func ScoreSentiment(lang string, tokens []string) Sentiment {
+	lex, neg := lexicons[lang], negators[lang]
+	var s Sentiment
+	for i, t := range tokens {
+		v, ok := lex[t]
+		if !ok {
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+			if neg[tokens[j]] {
+				v = -v
+				break
+			}
+		}
+		s.Score += v
+	}
+	if len(tokens) > 0 {
+		s.Comparative = s.Score / float64(len(tokens))
+	}
+	switch {
+	case s.Comparative > neutralBand:
+		s.Label = "positive"
+	case s.Comparative < -neutralBand:
+		s.Label = "negative"
+	default:
+		s.Label = "neutral"
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+// Stopword lists used by text analytics, one per supported language.
+
+package analytics
+
+import "strings"
+
+// Languages lists the language codes with stopword lists, in detection
+// tie-break order.
+var Languages = []string{"en", "es", "fr", "de"}
+
+var stopwords = map[string]map[string]bool{
+	"en": wordSet(`a about above after again against all am an and any are aren't as at be because been before
+		being below between both but by can can't cannot could couldn't did didn't do does doesn't doing don't down
+		during each few for from further had hadn't has hasn't have haven't having he he'd he'll he's her here here's
+		hers herself him himself his how how's i i'd i'll i'm i've if in into is isn't it it's its itself just let's
+		me more most mustn't my myself no nor not of off on once only or other ought our ours ourselves out over own
+		really same shan't she she'd she'll she's should shouldn't so some such than that that's the their theirs
+		them themselves then there there's these they they'd they'll they're they've this those through to too
+		under until up very was wasn't we we'd we'll we're we've were weren't what what's when when's where where's
+		which while who who's whom why why's will with won't would wouldn't you you'd you'll you're you've your
+		yours yourself yourselves also get got like would one`),
+	"es": wordSet(`a al algo algunas algunos ante antes como con contra cual cuando de del desde donde durante e el
+		ella ellas ellos en entre era eran es esa esas ese eso esos esta estaba estado estas este esto estos fue
+		fueron ha hay la las le les lo los mas me mi mis mucho muy más nada ni no nos nosotros o os otra otro para
+		pero poco por porque que quien se ser si sin sobre son su sus también tanto te tengo ti tu tus un una uno
+		unos y ya yo él está están qué`),
+	"fr": wordSet(`a ai au aux avec avez avons c ce ces cet cette d dans de des du elle elles en est et était il ils
+		j je l la le les leur leurs lui m ma mais me mes moi mon même n ne nos notre nous on ont ou où par pas peu
+		plus pour qu que qui s sa sans se ses si son sont sur ta te tes toi ton tous tout très tu un une vos votre
+		vous y à été être c'est j'ai n'est qu'il`),
+	"de": wordSet(`aber alle als also am an auch auf aus bei bin bis bist da damit dann das dass dein deine dem den
+		der des die dies diese dieser dieses doch dort du durch ein eine einem einen einer eines er es etwas euch
+		euer für hab habe haben hat hatte ich ihr ihre im in ist ja jetzt kann kein keine man mein meine mich mir
+		mit muss nach nicht noch nun nur ob oder ohne sehr sein seine sich sie sind so sonst über um und uns unser
+		von vor war waren was weil wenn wer wie wir wird wo zu zum zur`),
+}
+
+func wordSet(list string) map[string]bool {
+	out := map[string]bool{}
+	for _, w := range strings.Fields(list) {
+		out[w] = true
+	}
+	return out
+}
+
+// IsStopword reports whether word (lowercase) is a stopword in lang.
+func IsStopword(lang, word string) bool {
+	return stopwords[lang][word]
+}
+
+// DetectLanguage picks the language whose stopwords cover the most tokens,
+// falling back to English when nothing matches.
+func DetectLanguage(tokens []string) string {
+	best, bestHits := "en", 0
+	for _, lang := range Languages {
+		hits := 0
+		for _, t := range tokens {
+			if stopwords[lang][t] {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = lang, hits
+		}
+	}
+	return best
+}
@@ -0,0 +1,256 @@
+// Text answer analytics: lengths, top words and bigrams, a word-cloud table
+// and sentiment, computed locally from streamed answers.
+
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// detectSample is how many answers are buffered to detect the language
+	// when none is given.
+	detectSample = 200
+	// wordCloudSize caps the word-cloud table.
+	wordCloudSize = 100
+)
+
+// TextFieldResult summarizes the answers of one text field.
+type TextFieldResult struct {
+	FieldID    string            `json:"fieldId"`
+	Label      string            `json:"label"`
+	Language   string            `json:"language"`
+	Responses  int64             `json:"responses"`
+	AvgLength  float64           `json:"avgLength"` // code points
+	AvgWords   float64           `json:"avgWords"`
+	TopWords   []TermCount       `json:"topWords"`
+	TopBigrams []TermCount       `json:"topBigrams"`
+	WordCloud  []CloudWord       `json:"wordCloud"`
+	Sentiment  *SentimentSummary `json:"sentiment,omitempty"` // nil without a lexicon for Language
+	Answers    []AnswerSentiment `json:"answers,omitempty"`
+}
+
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int64  `json:"count"`
+}
+
+// CloudWord is a word-cloud entry; Weight is Count relative to the most
+// frequent word (0..1].
+type CloudWord struct {
+	Text   string  `json:"text"`
+	Count  int64   `json:"count"`
+	Weight float64 `json:"weight"`
+}
+
+type SentimentSummary struct {
+	AvgScore       float64 `json:"avgScore"`
+	AvgComparative float64 `json:"avgComparative"`
+	Positive       int64   `json:"positive"`
+	Neutral        int64   `json:"neutral"`
+	Negative       int64   `json:"negative"`
+}
+
+// AnswerSentiment is the sentiment of a single response's answer.
+type AnswerSentiment struct {
+	ResponseID  string    `json:"responseId"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	Sentiment
+}
+
+// Tokenize lowercases s and splits it into words; apostrophes inside a word
+// are kept so contractions match the stopword and negator lists.
+func Tokenize(s string) []string {
+	var out []string
+	var b strings.Builder
+	flush := func() {
+		if w := strings.Trim(b.String(), "'’"); w != "" {
+			out = append(out, strings.ReplaceAll(w, "’", "'"))
+		}
+		b.Reset()
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case (r == '\'' || r == '’') && b.Len() > 0:
+			b.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// isTerm reports whether a token is worth counting: not a stopword, at
+// least two characters and not purely numeric.
+func isTerm(lang, t string) bool {
+	if utf8.RuneCountInString(t) < 2 || IsStopword(lang, t) {
+		return false
+	}
+	for _, r := range t {
+		if !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type textAnswer struct {
+	id     string
+	at     time.Time
+	length int
+	tokens []string
+}
+
+// TextAnalyzer accumulates answers of one field. Feed it with Add, most
+// relevant (e.g. most recent) first when keeping per-answer sentiment.
+type TextAnalyzer struct {
+	field TextFieldResult
+	lang  string // "" until detected
+	keep  int
+
+	pending []textAnswer
+	chars   int64
+	words   int64
+	terms   map[string]int64
+	bigrams map[string]int64
+	score   float64
+	comp    float64
+}
+
+// NewTextAnalyzer prepares an analyzer. An empty lang is detected from the
+// first answers; keep is how many per-answer sentiments to retain.
+func NewTextAnalyzer(fieldID, label, lang string, keep int) *TextAnalyzer {
+	return &TextAnalyzer{
+		field:   TextFieldResult{FieldID: fieldID, Label: label},
+		lang:    lang,
+		keep:    keep,
+		terms:   map[string]int64{},
+		bigrams: map[string]int64{},
+	}
+}
+
+// Add records one answer; blank answers are ignored.
+func (a *TextAnalyzer) Add(responseID string, submittedAt time.Time, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	ans := textAnswer{id: responseID, at: submittedAt, length: utf8.RuneCountInString(text), tokens: Tokenize(text)}
+	if a.lang != "" {
+		a.process(ans)
+		return
+	}
+	a.pending = append(a.pending, ans)
+	if len(a.pending) >= detectSample {
+		a.detect()
+	}
+}
+
+func (a *TextAnalyzer) detect() {
+	var sample []string
+	for _, p := range a.pending {
+		sample = append(sample, p.tokens...)
+	}
+	a.lang = DetectLanguage(sample)
+	for _, p := range a.pending {
+		a.process(p)
+	}
+	a.pending = nil
+}
+
+func (a *TextAnalyzer) process(ans textAnswer) {
+	a.field.Responses++
+	a.chars += int64(ans.length)
+	a.words += int64(len(ans.tokens))
+
+	for i, t := range ans.tokens {
+		if !isTerm(a.lang, t) {
+			continue
+		}
+		a.terms[t]++
+		if i+1 < len(ans.tokens) && isTerm(a.lang, ans.tokens[i+1]) {
+			a.bigrams[t+" "+ans.tokens[i+1]]++
+		}
+	}
+
+	if !HasLexicon(a.lang) {
+		return
+	}
+	s := ScoreSentiment(a.lang, ans.tokens)
+	a.score += s.Score
+	a.comp += s.Comparative
+	if a.field.Sentiment == nil {
+		a.field.Sentiment = &SentimentSummary{}
+	}
+	switch s.Label {
+	case "positive":
+		a.field.Sentiment.Positive++
+	case "negative":
+		a.field.Sentiment.Negative++
+	default:
+		a.field.Sentiment.Neutral++
+	}
+	if len(a.field.Answers) < a.keep {
+		a.field.Answers = append(a.field.Answers, AnswerSentiment{ResponseID: ans.id, SubmittedAt: ans.at, Sentiment: s})
+	}
+}
+
+// Result finishes the analysis, returning the top n words and bigrams.
+func (a *TextAnalyzer) Result(n int) TextFieldResult {
+	if a.lang == "" {
+		a.detect()
+	}
+	out := a.field
+	out.Language = a.lang
+	if out.Responses > 0 {
+		out.AvgLength = float64(a.chars) / float64(out.Responses)
+		out.AvgWords = float64(a.words) / float64(out.Responses)
+	}
+	if out.Sentiment != nil {
+		s := *out.Sentiment
+		s.AvgScore = a.score / float64(out.Responses)
+		s.AvgComparative = a.comp / float64(out.Responses)
+		out.Sentiment = &s
+	}
+
+	words := topTerms(a.terms, 0)
+	out.TopWords = limitTerms(words, n)
+	out.TopBigrams = topTerms(a.bigrams, n)
+	out.WordCloud = []CloudWord{}
+	for _, w := range limitTerms(words, wordCloudSize) {
+		out.WordCloud = append(out.WordCloud, CloudWord{
+			Text:   w.Term,
+			Count:  w.Count,
+			Weight: float64(w.Count) / float64(words[0].Count),
+		})
+	}
+	return out
+}
+
+// topTerms sorts counts by frequency, then alphabetically; n <= 0 keeps all.
+func topTerms(counts map[string]int64, n int) []TermCount {
+	out := make([]TermCount, 0, len(counts))
+	for t, c := range counts {
+		out = append(out, TermCount{Term: t, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Term < out[j].Term
+	})
+	return limitTerms(out, n)
+}
+
+func limitTerms(ts []TermCount, n int) []TermCount {
+	if n > 0 && len(ts) > n {
+		return ts[:n]
+	}
+	return ts
+}
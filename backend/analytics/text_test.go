@@ -0,0 +1,162 @@
+package analytics
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"I don't like it", []string{"i", "don't", "like", "it"}},
+		{"It isn’t great", []string{"it", "isn't", "great"}},
+		{"'quoted' ’curly’", []string{"quoted", "curly"}},
+		{"rock'n'roll", []string{"rock'n'roll"}},
+		{"version 2.0 rocks", []string{"version", "2", "0", "rocks"}},
+		{"Très ÉLÉGANT", []string{"très", "élégant"}},
+		{"  ...  ", nil},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScoreSentiment(t *testing.T) {
+	tests := []struct {
+		name  string
+		lang  string
+		text  string
+		score float64
+		label string
+	}{
+		{"positive", "en", "great app", 3, "positive"},
+		{"negative", "en", "terrible support", -3, "negative"},
+		{"negated", "en", "not good", -3, "negative"},
+		{"negated contraction", "en", "it isn’t good", -3, "negative"},
+		{"negation within window", "en", "not at all good", -3, "negative"},
+		{"negation out of window", "en", "not that it was really good", 3, "positive"},
+		{"later hit past the window", "en", "no bugs at all, great", 5, "positive"},
+		{"negated negative", "en", "never slow", 2, "positive"},
+		{"neutral", "en", "the form has five questions", 0, "neutral"},
+		{"mixed cancel out", "en", "good but bad", 0, "neutral"},
+		{"spanish negation", "es", "no es bueno", -3, "negative"},
+		{"no lexicon", "xx", "great", 0, "neutral"},
+	}
+	for _, tt := range tests {
+		tokens := Tokenize(tt.text)
+		s := ScoreSentiment(tt.lang, tokens)
+		if s.Score != tt.score || s.Label != tt.label {
+			t.Errorf("%s: %q scored %v (%s), want %v (%s)", tt.name, tt.text, s.Score, s.Label, tt.score, tt.label)
+		}
+		if want := tt.score / float64(len(tokens)); math.Abs(s.Comparative-want) > 1e-12 {
+			t.Errorf("%s: comparative %v, want %v", tt.name, s.Comparative, want)
+		}
+	}
+	if s := ScoreSentiment("en", nil); s.Comparative != 0 || s.Label != "neutral" {
+		t.Errorf("no tokens: %+v", s)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"the app is fast and I like the design", "en"},
+		{"la aplicación es muy rápida y el diseño es bueno", "es"},
+		{"le formulaire est très simple et nous avons aimé", "fr"},
+		{"die App ist sehr schnell und ich mag das Design", "de"},
+		// Mostly Spanish with a few English words.
+		{"the app: el diseño de la página es muy bueno pero el formulario es lento", "es"},
+		{"xyzzy plugh", "en"},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(Tokenize(tt.text)); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTextAnalyzerResult(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := NewTextAnalyzer("f1", "Feedback", "", 2)
+	for i, text := range []string{
+		"The checkout flow is great",
+		"checkout flow was slow",
+		"   ",
+		"I love the checkout flow, it's not slow",
+		"42 42 ok",
+	} {
+		a.Add(string(rune('a'+i)), at, text)
+	}
+	res := a.Result(3)
+
+	if res.FieldID != "f1" || res.Label != "Feedback" || res.Language != "en" {
+		t.Errorf("header = %+v", res)
+	}
+	if res.Responses != 4 {
+		t.Errorf("responses = %d, want 4 (blank answers skipped)", res.Responses)
+	}
+	if math.Abs(res.AvgWords-float64(5+4+8+3)/4) > 1e-12 {
+		t.Errorf("avg words = %v", res.AvgWords)
+	}
+
+	// Stopwords ("the", "is", "it's"), single letters and numbers never count.
+	wantWords := []TermCount{{"checkout", 3}, {"flow", 3}, {"slow", 2}}
+	if !reflect.DeepEqual(res.TopWords, wantWords) {
+		t.Errorf("top words = %v, want %v", res.TopWords, wantWords)
+	}
+	for _, w := range res.WordCloud {
+		if IsStopword("en", w.Text) || w.Text == "42" {
+			t.Errorf("word cloud contains %q", w.Text)
+		}
+	}
+	if res.WordCloud[0].Weight != 1 || res.WordCloud[len(res.WordCloud)-1].Weight != 1.0/3 {
+		t.Errorf("cloud weights = %+v", res.WordCloud)
+	}
+
+	// Bigrams only join adjacent counted terms: "flow was slow" and "love the
+	// checkout" have none.
+	wantBigrams := []TermCount{{"checkout flow", 3}}
+	if !reflect.DeepEqual(res.TopBigrams, wantBigrams) {
+		t.Errorf("top bigrams = %v, want %v", res.TopBigrams, wantBigrams)
+	}
+	for _, b := range res.TopBigrams {
+		for _, w := range strings.Fields(b.Term) {
+			if IsStopword("en", w) {
+				t.Errorf("bigram %q contains a stopword", b.Term)
+			}
+		}
+	}
+
+	s := res.Sentiment
+	if s == nil || s.Positive != 2 || s.Negative != 1 || s.Neutral != 1 {
+		t.Fatalf("sentiment = %+v, want 2 positive, 1 negative, 1 neutral", s)
+	}
+	if math.Abs(s.AvgScore-(3-2+(3+2)+0)/4.0) > 1e-12 {
+		t.Errorf("avg score = %v", s.AvgScore)
+	}
+	if len(res.Answers) != 2 || res.Answers[0].ResponseID != "a" || !res.Answers[0].SubmittedAt.Equal(at) {
+		t.Errorf("kept answers = %+v, want the first 2", res.Answers)
+	}
+}
+
+func TestTextAnalyzerWithoutLexicon(t *testing.T) {
+	a := NewTextAnalyzer("f", "F", "xx", 5)
+	a.Add("r", time.Time{}, "alpha beta")
+	res := a.Result(10)
+	if res.Language != "xx" || res.Sentiment != nil || res.Answers != nil {
+		t.Errorf("result = %+v, want no sentiment", res)
+	}
+	if empty := NewTextAnalyzer("f", "F", "", 5).Result(10); empty.Responses != 0 || len(empty.WordCloud) != 0 {
+		t.Errorf("empty result = %+v", empty)
+	}
+}
@@ -4,7 +4,10 @@ package config
 
 import (
 	"context"
+	"errors"
 	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -34,6 +37,16 @@ func EnsureIndexes(db *mongo.Database) {
 		log.Printf("index create (forms ownerId+updatedAt) failed: %v", err)
 	}
 
+	// Dashboard listing without an owner: keyset pages on updatedAt + _id.
+	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "updatedAt", Value: -1},
+			{Key: "_id", Value: -1},
+		},
+	}); err != nil {
+		log.Printf("index create (forms updatedAt+_id) failed: %v", err)
+	}
+
 	// Unique slug for public/shareable links.
 	// Sparse ensures uniqueness only when slug exists (allows null/absent).
 	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
@@ -43,6 +56,38 @@ func EnsureIndexes(db *mongo.Database) {
 		log.Printf("index create (forms slug) failed: %v", err)
 	}
 
+	// Previous slugs redirect to the current one, so they stay globally unique too.
+	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "previousSlugs", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	}); err != nil {
+		log.Printf("index create (forms previousSlugs) failed: %v", err)
+	}
+
+	// Template library listing; partial so only templates are indexed (a sparse
+	// index would still hold every form with isTemplate: false). Earlier
+	// versions created a sparse index on the same keys, which is dropped here.
+	_, _ = forms.Indexes().DropOne(ctx, "isTemplate_1_title_1")
+	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "isTemplate", Value: 1}, {Key: "title", Value: 1}},
+		Options: options.Index().
+			SetName("templates_title").
+			SetPartialFilterExpression(bson.M{"isTemplate": true}),
+	}); err != nil {
+		log.Printf("index create (forms isTemplate+title) failed: %v", err)
+	}
+
+	// Lifecycle scheduler: scans by status, then opensAt/closesAt ranges.
+	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "closesAt", Value: 1},
+			{Key: "opensAt", Value: 1},
+		},
+	}); err != nil {
+		log.Printf("index create (forms status+closesAt+opensAt) failed: %v", err)
+	}
+
 	//----------------------------responses indexes---------------------------------
 
 	// Analytics + fetch latest responses per form: formId equality + submittedAt desc.
@@ -55,6 +100,17 @@ func EnsureIndexes(db *mongo.Database) {
 		log.Printf("index create (responses formId+submittedAt) failed: %v", err)
 	}
 
+	// Keyset pagination: submittedAt with _id as the tie-breaker for a stable order.
+	if _, err := responses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "formId", Value: 1},
+			{Key: "submittedAt", Value: -1},
+			{Key: "_id", Value: -1},
+		},
+	}); err != nil {
+		log.Printf("index create (responses formId+submittedAt+_id) failed: %v", err)
+	}
+
 	// Wildcard index on answers.* for flexible filtering; monitor size/perf impact.
 	if _, err := responses.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.D{{Key: "answers.$**", Value: 1}},
@@ -63,5 +119,114 @@ func EnsureIndexes(db *mongo.Database) {
 		log.Printf("index create (responses answers wildcard) failed: %v", err)
 	}
 
+	// Trash listing and purge: sparse so live documents stay out of the index.
+	if _, err := forms.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}); err != nil {
+		log.Printf("index create (forms deletedAt) failed: %v", err)
+	}
+	if _, err := responses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}); err != nil {
+		log.Printf("index create (responses deletedAt) failed: %v", err)
+	}
+
+	//----------------------------export job indexes---------------------------------
+
+	exports := db.Collection("exports")
+
+	// Worker claims the oldest queued job and scans for expired artifacts.
+	if _, err := exports.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "status", Value: 1},
+			{Key: "createdAt", Value: 1},
+		},
+	}); err != nil {
+		log.Printf("index create (exports status+createdAt) failed: %v", err)
+	}
+
+	// Full-text search over text answers, combined with formId equality.
+	if _, err := responses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "formId", Value: 1},
+			{Key: "searchText", Value: "text"},
+		},
+	}); err != nil {
+		log.Printf("index create (responses searchText) failed: %v", err)
+	}
+
+	// Funnel: submissions joined back to respondent sessions.
+	if _, err := responses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "formId", Value: 1}, {Key: "sessionId", Value: 1}},
+	}); err != nil {
+		log.Printf("index create (responses formId+sessionId) failed: %v", err)
+	}
+
+	//----------------------------funnel event indexes---------------------------------
+
+	events := db.Collection("events")
+
+	// Funnel aggregation: events of one form within a time range.
+	if _, err := events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "formId", Value: 1}, {Key: "at", Value: 1}},
+	}); err != nil {
+		log.Printf("index create (events formId+at) failed: %v", err)
+	}
+
+	// A session's view and start are recorded once (see recordFormEvent); the
+	// unique indexes settle concurrent requests from the same session.
+	for _, typ := range []string{"view", "start"} {
+		if _, err := events.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "formId", Value: 1}, {Key: "sessionId", Value: 1}},
+			Options: options.Index().
+				SetName("session_" + typ).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"type": typ, "sessionId": bson.M{"$exists": true}}),
+		}); err != nil {
+			log.Printf("index create (events session %s) failed: %v", typ, err)
+		}
+	}
+
+	// Retention: raw events expire after EVENT_RETENTION_DAYS. A changed
+	// setting is applied to the existing TTL index in place.
+	ttl := int32(eventRetentionDays() * 24 * 3600)
+	if _, err := events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "at", Value: 1}},
+		Options: options.Index().SetName("at_ttl").SetExpireAfterSeconds(ttl),
+	}); err != nil {
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == 85 { // IndexOptionsConflict
+			err = db.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: events.Name()},
+				{Key: "index", Value: bson.M{"name": "at_ttl", "expireAfterSeconds": ttl}},
+			}).Err()
+		}
+		if err != nil {
+			log.Printf("index create (events at TTL) failed: %v", err)
+		}
+	}
+
+	//----------------------------realtime relay indexes---------------------------------
+
+	// Relayed realtime events are only needed while instances catch up.
+	if _, err := db.Collection("realtime_events").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(3600),
+	}); err != nil {
+		log.Printf("index create (realtime_events at TTL) failed: %v", err)
+	}
+
 	log.Println("Indexes ensured")
 }
+
+// eventRetentionDays reads EVENT_RETENTION_DAYS, how long funnel events are
+// kept, falling back to 180 days.
+func eventRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("EVENT_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 180
+	}
+	return days
+}
@@ -0,0 +1,285 @@
+// Coalesced analytics pushes: one computation per form and filter at most
+// every interval, shared by every stream subscribed to it.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+)
+
+const (
+	defaultAnalyticsPushMs = 1000
+	analyticsPushTimeout   = 30 * time.Second
+)
+
+// analyticsPushInterval reads ANALYTICS_PUSH_INTERVAL_MS, the minimum gap
+// between analytics computations per form, falling back to 1 second.
+func analyticsPushInterval() time.Duration {
+	ms, err := strconv.Atoi(os.Getenv("ANALYTICS_PUSH_INTERVAL_MS"))
+	if err != nil || ms < 0 {
+		ms = defaultAnalyticsPushMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// analyticsUpdate is one computed snapshot. ID is the newest realtime event
+// it reflects.
+type analyticsUpdate struct {
+	ID     uint64
+	Result analytics.Result
+	Err    error
+}
+
+// pushStats counts what the coalescing saved:
+//
+//	computed  — snapshots actually aggregated
+//	coalesced — submissions folded into an already scheduled computation
+//	skipped   — snapshots replaced before a slow subscriber took them
+type pushStats struct {
+	Computed  int64 `json:"computed"`
+	Coalesced int64 `json:"coalesced"`
+	Skipped   int64 `json:"skipped"`
+}
+
+var analyticsPushTotals struct {
+	computed, coalesced, skipped atomic.Int64
+}
+
+// analyticsChannel is the shared computation for one form and segment.
+type analyticsChannel struct {
+	key      string
+	db       *mongo.Database
+	formID   primitive.ObjectID
+	segment  analyticsSegment
+	match    bson.M
+	interval time.Duration
+
+	mu      sync.Mutex
+	subs    map[chan analyticsUpdate]struct{}
+	latest  *analyticsUpdate
+	newest  uint64
+	edits   uint64 // bumped by form edits; older runs aren't cached
+	running bool
+	pending bool
+	timer   *time.Timer
+	lastRun time.Time
+	closed  bool
+	stats   pushStats
+	stopRT  func()
+}
+
+var (
+	analyticsChannelsMu sync.Mutex
+	analyticsChannels   = map[string]*analyticsChannel{}
+)
+
+// subscribeAnalytics follows the coalesced snapshots of formID over seg
+// (whose $match is match). With initial set the subscriber gets the latest
+// snapshot right away, computing one if there is none yet. The channel
+// holds at most one undelivered snapshot; newer ones replace it. stop
+// closes the channel.
+func subscribeAnalytics(db *mongo.Database, formID primitive.ObjectID, seg analyticsSegment, match bson.M, initial bool) (updates <-chan analyticsUpdate, stop func()) {
+	segKey, _ := json.Marshal(seg)
+	key := formID.Hex() + " " + string(segKey)
+
+	analyticsChannelsMu.Lock()
+	ch, ok := analyticsChannels[key]
+	if !ok {
+		ch = &analyticsChannel{
+			key:      key,
+			db:       db,
+			formID:   formID,
+			segment:  seg,
+			match:    match,
+			interval: analyticsPushInterval(),
+			subs:     map[chan analyticsUpdate]struct{}{},
+		}
+		analyticsChannels[key] = ch
+		ch.follow()
+	}
+	sub := make(chan analyticsUpdate, 1)
+	ch.mu.Lock()
+	ch.subs[sub] = struct{}{}
+	cached := initial && ch.latest != nil
+	if cached {
+		sub <- *ch.latest
+	}
+	ch.mu.Unlock()
+	analyticsChannelsMu.Unlock()
+
+	if initial && !cached {
+		ch.trigger(0)
+	}
+
+	var once sync.Once
+	return sub, func() {
+		once.Do(func() { ch.leave(sub) })
+	}
+}
+
+// follow starts listening for submissions and edits to the form. An edit
+// also drops the cached snapshot, so nobody joining before the recompute
+// finishes is served analytics of the old definition.
+func (ch *analyticsChannel) follow() {
+	rs := rtSubscribe(ch.formID.Hex(), 0)
+	ch.stopRT = rs.Unsubscribe
+	go func() {
+		for ev := range rs.C {
+			if !rtAffectsAnalytics(ev) {
+				continue
+			}
+			if ev.Type != rtEventResponse {
+				ch.mu.Lock()
+				ch.latest = nil
+				ch.edits++
+				ch.mu.Unlock()
+			}
+			ch.trigger(ev.ID)
+		}
+	}()
+}
+
+func (ch *analyticsChannel) leave(sub chan analyticsUpdate) {
+	analyticsChannelsMu.Lock()
+	defer analyticsChannelsMu.Unlock()
+	ch.mu.Lock()
+	delete(ch.subs, sub)
+	close(sub)
+	last := len(ch.subs) == 0
+	if last {
+		ch.closed = true
+		if ch.timer != nil {
+			ch.timer.Stop()
+		}
+		delete(analyticsChannels, ch.key)
+	}
+	ch.mu.Unlock()
+	if last {
+		ch.stopRT()
+	}
+}
+
+// trigger asks for a snapshot reflecting event id. It runs at once unless a
+// computation is running or the interval hasn't passed, in which case one
+// more run is scheduled (pending) and further triggers fold into it.
+func (ch *analyticsChannel) trigger(id uint64) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if id > ch.newest {
+		ch.newest = id
+	}
+	if ch.closed {
+		return
+	}
+	if ch.running || ch.timer != nil {
+		if ch.pending {
+			ch.stats.Coalesced++
+			analyticsPushTotals.coalesced.Add(1)
+		}
+		ch.pending = true
+		return
+	}
+	if wait := time.Until(ch.lastRun.Add(ch.interval)); wait > 0 {
+		ch.timer = time.AfterFunc(wait, ch.fire)
+		ch.pending = true
+		return
+	}
+	ch.startLocked()
+}
+
+func (ch *analyticsChannel) fire() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.timer = nil
+	if !ch.closed {
+		ch.startLocked()
+	}
+}
+
+// startLocked launches a computation. Must hold ch.mu.
+func (ch *analyticsChannel) startLocked() {
+	ch.running, ch.pending = true, false
+	go ch.run(ch.newest, ch.edits)
+}
+
+func (ch *analyticsChannel) run(id, edits uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), analyticsPushTimeout)
+	res, err := streamSnapshot(ctx, ch.db, ch.formID, ch.match, ch.segment.filtered())
+	cancel()
+	upd := analyticsUpdate{ID: id, Result: res, Err: err}
+	analyticsPushTotals.computed.Add(1)
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.running = false
+	ch.lastRun = time.Now()
+	ch.stats.Computed++
+	if err == nil && edits == ch.edits {
+		ch.latest = &upd
+	}
+	for sub := range ch.subs {
+		select {
+		case <-sub:
+			ch.stats.Skipped++
+			analyticsPushTotals.skipped.Add(1)
+		default:
+		}
+		sub <- upd
+	}
+	if ch.pending && !ch.closed {
+		ch.timer = time.AfterFunc(ch.interval, ch.fire)
+	}
+}
+
+// GET /realtime/metrics
+func AnalyticsPushMetrics(c *fiber.Ctx) error {
+	type channelStats struct {
+		FormID         string           `json:"formId"`
+		Segment        analyticsSegment `json:"segment"`
+		Subscribers    int              `json:"subscribers"`
+		LastComputedAt *time.Time       `json:"lastComputedAt"`
+		pushStats
+	}
+
+	analyticsChannelsMu.Lock()
+	channels := make([]channelStats, 0, len(analyticsChannels))
+	for _, ch := range analyticsChannels {
+		ch.mu.Lock()
+		cs := channelStats{
+			FormID:      ch.formID.Hex(),
+			Segment:     ch.segment,
+			Subscribers: len(ch.subs),
+			pushStats:   ch.stats,
+		}
+		if !ch.lastRun.IsZero() {
+			t := ch.lastRun
+			cs.LastComputedAt = &t
+		}
+		ch.mu.Unlock()
+		channels = append(channels, cs)
+	}
+	analyticsChannelsMu.Unlock()
+
+	return c.JSON(fiber.Map{
+		"intervalMs": analyticsPushInterval().Milliseconds(),
+		"totals": pushStats{
+			Computed:  analyticsPushTotals.computed.Load(),
+			Coalesced: analyticsPushTotals.coalesced.Load(),
+			Skipped:   analyticsPushTotals.skipped.Load(),
+		},
+		"channels": channels,
+	})
+}
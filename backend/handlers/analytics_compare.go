@@ -0,0 +1,195 @@
+// Side-by-side analytics for two response segments (date ranges, form
+// versions or answer filters) with deltas and significance tests.
+
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+)
+
+// compareAlpha is the significance level for the comparison tests.
+const compareAlpha = 0.05
+
+// comparisonDelta pairs a metric across segments. Deltas are B relative to
+// A; Pct is nil when A is zero.
+type comparisonDelta struct {
+	A   float64  `json:"a"`
+	B   float64  `json:"b"`
+	Abs float64  `json:"abs"`
+	Pct *float64 `json:"pct"`
+}
+
+func compareValues(a, b float64) comparisonDelta {
+	d := comparisonDelta{A: a, B: b, Abs: b - a}
+	if a != 0 {
+		pct := 100 * (b - a) / a
+		d.Pct = &pct
+	}
+	return d
+}
+
+// compareOptional compares metrics that may be missing on either side.
+func compareOptional(a, b *float64) *comparisonDelta {
+	if a == nil || b == nil {
+		return nil
+	}
+	d := compareValues(*a, *b)
+	return &d
+}
+
+type ratingComparison struct {
+	Mean   *comparisonDelta  `json:"mean"`
+	Median *comparisonDelta  `json:"median"`
+	StdDev *comparisonDelta  `json:"stdDev"`
+	Test   *significanceTest `json:"test"`
+}
+
+type optionComparison struct {
+	Option string            `json:"option"`
+	Count  comparisonDelta   `json:"count"`
+	Share  comparisonDelta   `json:"share"`
+	Test   *significanceTest `json:"test"`
+}
+
+type fieldComparison struct {
+	ID           string             `json:"id"`
+	Label        string             `json:"label"`
+	Type         string             `json:"type"`
+	Answered     comparisonDelta    `json:"answered"`
+	ResponseRate comparisonDelta    `json:"responseRate"`
+	Rating       *ratingComparison  `json:"rating,omitempty"`
+	Options      []optionComparison `json:"options,omitempty"`
+	AvgLength    *comparisonDelta   `json:"avgLength,omitempty"`
+}
+
+// compareRatings tests the difference in means with Welch's t-test.
+func compareRatings(a, b *analytics.RatingSummary) *ratingComparison {
+	if a == nil || b == nil {
+		return nil
+	}
+	rc := &ratingComparison{
+		Mean:   compareOptional(a.Avg, b.Avg),
+		Median: compareOptional(a.Median, b.Median),
+		StdDev: compareOptional(a.StdDev, b.StdDev),
+	}
+	if a.StdDev != nil && b.StdDev != nil {
+		rc.Test = welchTTest(*a.Avg, *a.StdDev, a.Count, *b.Avg, *b.StdDev, b.Count, compareAlpha)
+	}
+	return rc
+}
+
+// compareOptions pairs options by name, in A's order followed by options
+// only B has, and tests each share with a two-proportion z-test over the
+// responses that answered the field.
+func compareOptions(a, b analytics.FieldResult) []optionComparison {
+	if a.Options == nil && b.Options == nil {
+		return nil
+	}
+	var names []string
+	seen := map[string]bool{}
+	aOpt, bOpt := map[string]analytics.OptionCount{}, map[string]analytics.OptionCount{}
+	for _, o := range a.Options {
+		aOpt[o.Option] = o
+		if !seen[o.Option] {
+			seen[o.Option] = true
+			names = append(names, o.Option)
+		}
+	}
+	for _, o := range b.Options {
+		bOpt[o.Option] = o
+		if !seen[o.Option] {
+			seen[o.Option] = true
+			names = append(names, o.Option)
+		}
+	}
+
+	out := make([]optionComparison, 0, len(names))
+	for _, name := range names {
+		oa, ob := aOpt[name], bOpt[name]
+		out = append(out, optionComparison{
+			Option: name,
+			Count:  compareValues(float64(oa.Count), float64(ob.Count)),
+			Share:  compareValues(oa.Share, ob.Share),
+			Test:   twoProportionZTest(oa.Count, a.Answered, ob.Count, b.Answered, compareAlpha),
+		})
+	}
+	return out
+}
+
+// segmentResult looks up a field in either map of a result.
+func segmentResult(res analytics.Result, id string) analytics.FieldResult {
+	if fr, ok := res.Fields[id]; ok {
+		return fr
+	}
+	return res.Text[id]
+}
+
+// GET /forms/:id/analytics/compare
+//
+// Segments are given as ?a.from=&a.to=&a.version=&a.q= and the same with
+// "b."; A is the baseline. An omitted segment covers all live responses.
+// Fields are compared against the current form definition, so fields added
+// or removed between versions show as unanswered on one side.
+func FormAnalyticsCompare(c *fiber.Ctx) error {
+	db := c.Locals("db").(*mongo.Database)
+	formsCol := c.Locals("forms").(*mongo.Collection)
+
+	formID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	form, status, err := loadForm(c.Context(), formsCol, formID.Hex())
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	segA, segB := querySegment(c, "a."), querySegment(c, "b.")
+	if segA == segB {
+		return c.Status(400).JSON(fiber.Map{"error": "segments a and b are identical"})
+	}
+
+	names := []string{"a", "b"}
+	results := make([]analytics.Result, 2)
+	for i, seg := range []analyticsSegment{segA, segB} {
+		match, status, err := seg.match(c.Context(), formsCol, formID)
+		if err != nil {
+			return c.Status(status).JSON(fiber.Map{"error": "segment " + names[i] + ": " + err.Error()})
+		}
+		results[i], err = analytics.Snapshot(c.Context(), db, form, match, seg.filtered())
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed analytics aggregation"})
+		}
+	}
+	resA, resB := results[0], results[1]
+
+	fields := make([]fieldComparison, 0, len(form.Fields))
+	for _, f := range form.Fields {
+		a, b := segmentResult(resA, f.ID), segmentResult(resB, f.ID)
+		fc := fieldComparison{
+			ID:           f.ID,
+			Label:        f.Label,
+			Type:         f.Type,
+			Answered:     compareValues(float64(a.Answered), float64(b.Answered)),
+			ResponseRate: compareValues(a.ResponseRate, b.ResponseRate),
+			Rating:       compareRatings(a.Rating, b.Rating),
+			Options:      compareOptions(a, b),
+		}
+		if a.Text != nil && b.Text != nil {
+			d := compareValues(a.Text.AvgLength, b.Text.AvgLength)
+			fc.AvgLength = &d
+		}
+		fields = append(fields, fc)
+	}
+
+	return c.JSON(fiber.Map{
+		"a":              fiber.Map{"segment": segA, "totalResponses": resA.TotalResponses},
+		"b":              fiber.Map{"segment": segB, "totalResponses": resB.TotalResponses},
+		"totalResponses": compareValues(float64(resA.TotalResponses), float64(resB.TotalResponses)),
+		"alpha":          compareAlpha,
+		"fields":         fields,
+	})
+}
@@ -0,0 +1,189 @@
+// Cross-tabulation of two choice/rating fields: contingency table, row and
+// column percentages, and a chi-square test of independence.
+
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// crosstabField resolves a field ID to a field that can be tabulated.
+func crosstabField(form models.Form, id string) (models.Field, bool) {
+	for _, f := range form.Fields {
+		if f.ID == id {
+			return f, f.Type == "mc" || f.Type == "checkbox" || f.Type == "rating"
+		}
+	}
+	return models.Field{}, false
+}
+
+// crosstabCategories lists a field's categories in display order: declared
+// options (or the Min..Max rating scale) first, including ones nobody picked,
+// then any other values seen in the data.
+func crosstabCategories(f models.Field, seen map[string]bool) []string {
+	var cats []string
+	if f.Type == "rating" {
+		if f.Min != nil && f.Max != nil {
+			for v := *f.Min; v <= *f.Max; v++ {
+				cats = append(cats, strconv.Itoa(v))
+			}
+		}
+	} else {
+		cats = append(cats, f.Options...)
+	}
+
+	var extra []string
+	for v := range seen {
+		if !analytics.ContainsString(cats, v) {
+			extra = append(extra, v)
+		}
+	}
+	analytics.SortCategories(extra)
+	return append(cats, extra...)
+}
+
+// GET /forms/:id/analytics/crosstab?row=<fieldId>&col=<fieldId>&q=&from=&to=
+func FormAnalyticsCrosstab(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	respCol := c.Locals("responses").(*mongo.Collection)
+
+	formID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	form, status, err := loadForm(c.Context(), formsCol, formID.Hex())
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rowID, colID := c.Query("row"), c.Query("col")
+	if rowID == "" || colID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "row and col field ids are required"})
+	}
+	if rowID == colID {
+		return c.Status(400).JSON(fiber.Map{"error": "row and col must be different fields"})
+	}
+	rowField, ok := crosstabField(form, rowID)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "row must be a choice or rating field: " + rowID})
+	}
+	colField, ok := crosstabField(form, colID)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "col must be a choice or rating field: " + colID})
+	}
+
+	match, status, err := analyticsMatch(c, formID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Same $objectToArray unwinding as FormAnalytics, narrowed to the two
+	// fields and paired per response. Checkbox arrays unwind to one row per
+	// selected option; responses missing either answer drop out.
+	pickField := func(id string) bson.M {
+		return bson.M{"$filter": bson.M{
+			"input": "$kv",
+			"cond":  bson.M{"$eq": bson.A{"$$this.k", id}},
+		}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.M{"kv": bson.M{"$objectToArray": "$answers"}}}},
+		{{Key: "$project", Value: bson.M{"r": pickField(rowID), "c": pickField(colID)}}},
+		{{Key: "$unwind", Value: "$r"}},
+		{{Key: "$unwind", Value: "$c"}},
+		{{Key: "$unwind", Value: "$r.v"}},
+		{{Key: "$unwind", Value: "$c.v"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"r": "$r.v", "c": "$c.v"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cur, err := respCol.Aggregate(c.Context(), pipeline)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed crosstab aggregation"})
+	}
+	var cells []struct {
+		ID struct {
+			R interface{} `bson:"r"`
+			C interface{} `bson:"c"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cur.All(c.Context(), &cells); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed reading crosstab aggregation"})
+	}
+
+	type cellKey struct{ r, c string }
+	counted := map[cellKey]int64{}
+	seenRows, seenCols := map[string]bool{}, map[string]bool{}
+	for _, cell := range cells {
+		r, ok1 := analytics.CategoryKey(cell.ID.R)
+		col, ok2 := analytics.CategoryKey(cell.ID.C)
+		if !ok1 || !ok2 {
+			continue
+		}
+		seenRows[r], seenCols[col] = true, true
+		counted[cellKey{r, col}] += cell.Count
+	}
+
+	rows := crosstabCategories(rowField, seenRows)
+	cols := crosstabCategories(colField, seenCols)
+
+	counts := make([][]int64, len(rows))
+	rowTotals := make([]int64, len(rows))
+	colTotals := make([]int64, len(cols))
+	var total int64
+	for i, r := range rows {
+		counts[i] = make([]int64, len(cols))
+		for j, col := range cols {
+			n := counted[cellKey{r, col}]
+			counts[i][j] = n
+			rowTotals[i] += n
+			colTotals[j] += n
+			total += n
+		}
+	}
+
+	rowPct := make([][]float64, len(rows))
+	colPct := make([][]float64, len(rows))
+	for i := range rows {
+		rowPct[i] = make([]float64, len(cols))
+		colPct[i] = make([]float64, len(cols))
+		for j := range cols {
+			if rowTotals[i] > 0 {
+				rowPct[i][j] = 100 * float64(counts[i][j]) / float64(rowTotals[i])
+			}
+			if colTotals[j] > 0 {
+				colPct[i][j] = 100 * float64(counts[i][j]) / float64(colTotals[j])
+			}
+		}
+	}
+
+	out := fiber.Map{
+		"row":               fiber.Map{"id": rowField.ID, "label": rowField.Label, "type": rowField.Type, "categories": rows},
+		"col":               fiber.Map{"id": colField.ID, "label": colField.Label, "type": colField.Type, "categories": cols},
+		"counts":            counts,
+		"rowTotals":         rowTotals,
+		"colTotals":         colTotals,
+		"total":             total,
+		"rowPercentages":    rowPct,
+		"columnPercentages": colPct,
+		"chiSquare":         chiSquare(counts),
+	}
+	if rowField.Type == "checkbox" || colField.Type == "checkbox" {
+		// Multi-select answers count a respondent once per selected option, so
+		// observations aren't independent and the test is only indicative.
+		out["warning"] = "checkbox fields count each selected option; chi-square is approximate"
+	}
+	return c.JSON(out)
+}
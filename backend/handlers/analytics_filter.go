@@ -0,0 +1,144 @@
+// Response subset selection for analytics: answer filters, a date range and
+// a form version.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// responseMatch is the $match for every live response of a form.
+func responseMatch(formID primitive.ObjectID) bson.M {
+	return bson.M{"formId": formID, "deletedAt": nil}
+}
+
+// analyticsSegment selects a subset of a form's responses:
+//
+//	q       — answer filters in the ListResponses query language (e.g. "dept=Engineering; rating>=4")
+//	from    — inclusive start, RFC3339 or YYYY-MM-DD
+//	to      — exclusive end; a bare date includes that whole day
+//	version — form version the responses were submitted against
+type analyticsSegment struct {
+	Q       string `json:"q,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// querySegment reads a segment from the query string; prefix is "" for the
+// plain analytics parameters or e.g. "a." for ?a.from=.
+func querySegment(c *fiber.Ctx, prefix string) analyticsSegment {
+	return analyticsSegment{
+		Q:       c.Query(prefix + "q"),
+		From:    c.Query(prefix + "from"),
+		To:      c.Query(prefix + "to"),
+		Version: c.Query(prefix + "version"),
+	}
+}
+
+// filtered reports whether the segment selects a subset of responses, which
+// rules out serving it from the stored counters.
+func (s analyticsSegment) filtered() bool {
+	return s != analyticsSegment{}
+}
+
+// match builds the segment's $match. The form is only loaded when q needs
+// field types. Errors are client errors except where status says otherwise.
+func (s analyticsSegment) match(ctx context.Context, formsCol *mongo.Collection, formID primitive.ObjectID) (bson.M, int, error) {
+	match := responseMatch(formID)
+	var and bson.A
+
+	if s.Q != "" {
+		form, status, err := loadForm(ctx, formsCol, formID.Hex())
+		if err != nil {
+			return nil, status, err
+		}
+		conds, err := compileResponseQuery(form, s.Q)
+		if err != nil {
+			return nil, 400, err
+		}
+		for _, cond := range conds {
+			and = append(and, cond)
+		}
+	}
+
+	from, to, err := parseRange(s.From, s.To)
+	if err != nil {
+		return nil, 400, err
+	}
+	if from != nil || to != nil {
+		rng := bson.M{}
+		if from != nil {
+			rng["$gte"] = *from
+		}
+		if to != nil {
+			rng["$lt"] = *to
+		}
+		and = append(and, bson.M{"submittedAt": rng})
+	}
+
+	if s.Version != "" {
+		v, err := strconv.ParseInt(s.Version, 10, 64)
+		if err != nil || v < 1 {
+			return nil, 400, errors.New("version must be a positive integer")
+		}
+		and = append(and, bson.M{"formVersion": v})
+	}
+
+	if len(and) > 0 {
+		match["$and"] = and
+	}
+	return match, 200, nil
+}
+
+// analyticsMatch builds the $match for an analytics request from ?q=, ?from=,
+// ?to= and ?version= (see analyticsSegment).
+func analyticsMatch(c *fiber.Ctx, formID primitive.ObjectID) (bson.M, int, error) {
+	return querySegment(c, "").match(c.Context(), c.Locals("forms").(*mongo.Collection), formID)
+}
+
+// analyticsFiltered reports whether the request selects a subset of
+// responses, which rules out serving it from the stored counters.
+func analyticsFiltered(c *fiber.Ctx) bool {
+	return querySegment(c, "").filtered()
+}
+
+// analyticsRange parses ?from= (inclusive) and ?to= (exclusive; a bare date
+// includes that whole day). Either may be nil.
+func analyticsRange(c *fiber.Ctx) (from, to *time.Time, err error) {
+	return parseRange(c.Query("from"), c.Query("to"))
+}
+
+// parseRange parses an inclusive start and exclusive end; empty strings
+// leave that bound nil.
+func parseRange(fromStr, toStr string) (from, to *time.Time, err error) {
+	if fromStr != "" {
+		t, _, err := parseQueryTime(fromStr)
+		if err != nil {
+			return nil, nil, errors.New("from: " + err.Error())
+		}
+		from = &t
+	}
+	if toStr != "" {
+		t, dayOnly, err := parseQueryTime(toStr)
+		if err != nil {
+			return nil, nil, errors.New("to: " + err.Error())
+		}
+		if dayOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = &t
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		return nil, nil, errors.New("to must be after from")
+	}
+	return from, to, nil
+}
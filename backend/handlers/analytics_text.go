@@ -0,0 +1,124 @@
+// Text answer analytics: word and bigram frequencies, a word-cloud table and
+// lexicon-based sentiment for a form's text fields.
+
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const (
+	defaultTopTerms   = 20
+	maxTopTerms       = 100
+	defaultAnswerKeep = 20
+	maxAnswerKeep     = 200
+)
+
+// boundedQueryInt reads a non-negative int param, falling back to def when
+// missing or invalid and capping at max.
+func boundedQueryInt(c *fiber.Ctx, key string, def, max int) int {
+	n, err := strconv.Atoi(c.Query(key, strconv.Itoa(def)))
+	if err != nil || n < 0 {
+		return def
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// GET /forms/:id/analytics/text?field=&lang=&top=&answers=&q=&from=&to=
+//
+// lang is one of analytics.Languages, or empty to detect it from the
+// answers; answers is how many of the most recent per-answer sentiments to
+// include.
+func FormTextAnalytics(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	respCol := c.Locals("responses").(*mongo.Collection)
+
+	formID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	form, status, err := loadForm(c.Context(), formsCol, formID.Hex())
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	lang := c.Query("lang")
+	if lang == "auto" {
+		lang = ""
+	}
+	if lang != "" && !analytics.ContainsString(analytics.Languages, lang) {
+		return c.Status(400).JSON(fiber.Map{"error": "lang must be one of " + joinQuoted(analytics.Languages)})
+	}
+	top := boundedQueryInt(c, "top", defaultTopTerms, maxTopTerms)
+	keep := boundedQueryInt(c, "answers", defaultAnswerKeep, maxAnswerKeep)
+
+	var fields []models.Field
+	for _, f := range form.Fields {
+		if f.Type != "text" {
+			continue
+		}
+		if id := c.Query("field"); id == "" || id == f.ID {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		if id := c.Query("field"); id != "" {
+			return c.Status(400).JSON(fiber.Map{"error": "not a text field: " + id})
+		}
+		return c.JSON(fiber.Map{"fields": []analytics.TextFieldResult{}})
+	}
+
+	match, status, err := analyticsMatch(c, formID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	proj := bson.M{"submittedAt": 1}
+	analyzers := make([]*analytics.TextAnalyzer, len(fields))
+	for i, f := range fields {
+		proj["answers."+f.ID] = 1
+		analyzers[i] = analytics.NewTextAnalyzer(f.ID, f.Label, lang, keep)
+	}
+
+	// Newest first, so the retained per-answer sentiments are the latest.
+	cur, err := respCol.Find(c.Context(), match, options.Find().
+		SetProjection(proj).
+		SetSort(bson.D{{Key: "submittedAt", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read responses"})
+	}
+	defer cur.Close(c.Context())
+
+	for cur.Next(c.Context()) {
+		var r models.Response
+		if err := cur.Decode(&r); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to read responses"})
+		}
+		for i, f := range fields {
+			if s, ok := r.Answers[f.ID].(string); ok {
+				analyzers[i].Add(r.ID.Hex(), r.SubmittedAt, s)
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read responses"})
+	}
+
+	out := make([]analytics.TextFieldResult, len(fields))
+	for i, a := range analyzers {
+		out[i] = a.Result(top)
+	}
+	return c.JSON(fiber.Map{"fields": out})
+}
@@ -0,0 +1,271 @@
+// Time-series analytics: submission counts, rating averages and option shares
+// bucketed by hour/day/week/month in a caller-supplied timezone.
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// maxSeriesBuckets bounds gap filling so a wide range at hourly resolution
+// can't produce an unbounded payload.
+const maxSeriesBuckets = 2000
+
+var seriesIntervals = []string{"hour", "day", "week", "month"}
+
+// seriesBucket is one point of the series. Options shares are the fraction of
+// the bucket's submissions that picked the option (checkbox shares may sum
+// past 1).
+type seriesBucket struct {
+	Start    time.Time                               `json:"start"`
+	Count    int64                                   `json:"count"`
+	Averages map[string]seriesAverage                `json:"averages"`
+	Options  map[string]map[string]seriesOptionShare `json:"options"`
+}
+
+type seriesAverage struct {
+	Avg   float64 `json:"avg"`
+	Count int64   `json:"count"`
+}
+
+type seriesOptionShare struct {
+	Count int64   `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// truncateBucket returns the start of the bucket containing t, matching
+// $dateTrunc with startOfWeek "monday".
+func truncateBucket(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch interval {
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// nextBucket steps in local calendar units so day buckets stay aligned to
+// local midnight across DST changes.
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// seriesFields picks the fields the series reports on, by ID.
+func seriesFields(form models.Form) (ratings map[string]bool, choices map[string]bool, ids bson.A) {
+	ratings, choices, ids = map[string]bool{}, map[string]bool{}, bson.A{}
+	for _, f := range form.Fields {
+		switch f.Type {
+		case "rating":
+			ratings[f.ID] = true
+		case "mc", "checkbox":
+			choices[f.ID] = true
+		default:
+			continue
+		}
+		ids = append(ids, f.ID)
+	}
+	return ratings, choices, ids
+}
+
+// GET /forms/:id/analytics/timeseries?interval=day&tz=Europe/Berlin&from=&to=&q=
+func FormAnalyticsTimeSeries(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	respCol := c.Locals("responses").(*mongo.Collection)
+
+	formID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	interval := c.Query("interval", "day")
+	if !analytics.ContainsString(seriesIntervals, interval) {
+		return c.Status(400).JSON(fiber.Map{"error": "interval must be one of " + joinQuoted(seriesIntervals)})
+	}
+	tz := c.Query("tz", "UTC")
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "unknown timezone: " + tz})
+	}
+
+	form, status, err := loadForm(c.Context(), formsCol, formID.Hex())
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	match, status, err := analyticsMatch(c, formID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	from, to, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ratings, choices, fieldIDs := seriesFields(form)
+
+	bucketExpr := bson.M{"$dateTrunc": bson.M{
+		"date":        "$submittedAt",
+		"unit":        interval,
+		"timezone":    loc.String(),
+		"startOfWeek": "monday",
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.M{"b": bucketExpr, "answers": 1}}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{"_id": "$b", "n": bson.M{"$sum": 1}}}},
+			},
+			// One row per (bucket, field, value); checkbox arrays unwind to
+			// their options, scalars pass through $unwind unchanged.
+			"values": mongo.Pipeline{
+				{{Key: "$project", Value: bson.M{"b": 1, "kv": bson.M{"$objectToArray": "$answers"}}}},
+				{{Key: "$unwind", Value: "$kv"}},
+				{{Key: "$match", Value: bson.M{"kv.k": bson.M{"$in": fieldIDs}}}},
+				{{Key: "$unwind", Value: "$kv.v"}},
+				{{Key: "$group", Value: bson.M{
+					"_id": bson.M{"b": "$b", "f": "$kv.k", "v": "$kv.v"},
+					"n":   bson.M{"$sum": 1},
+				}}},
+			},
+		}}},
+	}
+
+	cur, err := respCol.Aggregate(c.Context(), pipeline)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed time-series aggregation"})
+	}
+	var out []struct {
+		Counts []struct {
+			B time.Time `bson:"_id"`
+			N int64     `bson:"n"`
+		} `bson:"counts"`
+		Values []struct {
+			ID struct {
+				B time.Time   `bson:"b"`
+				F string      `bson:"f"`
+				V interface{} `bson:"v"`
+			} `bson:"_id"`
+			N int64 `bson:"n"`
+		} `bson:"values"`
+	}
+	if err := cur.All(c.Context(), &out); err != nil || len(out) == 0 {
+		return c.Status(500).JSON(fiber.Map{"error": "failed reading time-series aggregation"})
+	}
+
+	// Range defaults to first submission .. now.
+	var start, end time.Time
+	if from != nil {
+		start = *from
+	}
+	if to != nil {
+		end = *to
+	} else {
+		end = time.Now()
+	}
+	for _, r := range out[0].Counts {
+		if from == nil && (start.IsZero() || r.B.Before(start)) {
+			start = r.B
+		}
+	}
+	if start.IsZero() {
+		start = end
+	}
+
+	first := truncateBucket(start, interval, loc)
+	buckets := []*seriesBucket{}
+	index := map[int64]*seriesBucket{}
+	for b := first; b.Before(end) || b.Equal(first); b = nextBucket(b, interval) {
+		if len(buckets) == maxSeriesBuckets {
+			return c.Status(400).JSON(fiber.Map{"error": "range too large for interval; narrow from/to or use a coarser interval"})
+		}
+		sb := &seriesBucket{
+			Start:    b,
+			Averages: map[string]seriesAverage{},
+			Options:  map[string]map[string]seriesOptionShare{},
+		}
+		buckets = append(buckets, sb)
+		index[b.Unix()] = sb
+	}
+
+	for _, r := range out[0].Counts {
+		if sb := index[r.B.Unix()]; sb != nil {
+			sb.Count = r.N
+		}
+	}
+
+	sums := map[*seriesBucket]map[string]float64{}
+	for _, r := range out[0].Values {
+		sb := index[r.ID.B.Unix()]
+		if sb == nil {
+			continue
+		}
+		switch {
+		case ratings[r.ID.F]:
+			n, ok := analytics.ToFloat(r.ID.V)
+			if !ok {
+				continue
+			}
+			if sums[sb] == nil {
+				sums[sb] = map[string]float64{}
+			}
+			sums[sb][r.ID.F] += n * float64(r.N)
+			a := sb.Averages[r.ID.F]
+			a.Count += r.N
+			sb.Averages[r.ID.F] = a
+		case choices[r.ID.F]:
+			opt, ok := r.ID.V.(string)
+			if !ok {
+				continue
+			}
+			if sb.Options[r.ID.F] == nil {
+				sb.Options[r.ID.F] = map[string]seriesOptionShare{}
+			}
+			s := sb.Options[r.ID.F][opt]
+			s.Count += r.N
+			sb.Options[r.ID.F][opt] = s
+		}
+	}
+
+	for _, sb := range buckets {
+		for f, a := range sb.Averages {
+			a.Avg = sums[sb][f] / float64(a.Count)
+			sb.Averages[f] = a
+		}
+		for _, opts := range sb.Options {
+			for o, s := range opts {
+				s.Share = float64(s.Count) / float64(sb.Count)
+				opts[o] = s
+			}
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"interval": interval,
+		"timezone": loc.String(),
+		"from":     first,
+		"to":       end,
+		"buckets":  buckets,
+	})
+}
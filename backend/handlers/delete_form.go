@@ -1,12 +1,17 @@
-// DeleteForm removes a form by ID and cascades delete to its responses.
+// DeleteForm moves a form to the trash and cascades the soft delete to its responses.
 
 package handlers
 
 import (
+	"context"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
 )
 
 // DELETE /forms/:id
@@ -19,19 +24,68 @@ func DeleteForm(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
 	}
 
-	// Delete the form document by _id.
-	result, err := formsCol.DeleteOne(c.Context(), bson.M{"_id": oid})
-	if err != nil {
+	// Responses trashed by the cascade share the form's deletedAt, which is
+	// how a restore tells them apart from responses trashed individually.
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	found := false
+	cascade := func(ctx context.Context) error {
+		res, err := formsCol.UpdateOne(ctx,
+			bson.M{"_id": oid, "deletedAt": nil},
+			bson.M{"$set": bson.M{"deletedAt": deletedAt}},
+		)
+		if err != nil {
+			return err
+		}
+		if found = res.MatchedCount > 0; !found {
+			return nil
+		}
+		_, err = responsesCol.UpdateMany(ctx,
+			bson.M{"formId": oid, "deletedAt": nil},
+			bson.M{"$set": bson.M{"deletedAt": deletedAt}},
+		)
+		return err
+	}
+
+	if err := withTransaction(c.Context(), formsCol.Database().Client(), cascade); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to delete form"})
 	}
-	if result.DeletedCount == 0 {
+	if !found {
 		return c.Status(404).JSON(fiber.Map{"error": "form not found"})
 	}
+	invalidatePlan(oid)
+
+	// No content returned on success.
+	return c.SendStatus(204)
+}
+
+// DELETE /forms/:id/responses/:responseId
+func DeleteResponse(c *fiber.Ctx) error {
+	col := c.Locals("responses").(*mongo.Collection)
+
+	formID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	respID, err := primitive.ObjectIDFromHex(c.Params("responseId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid response id"})
+	}
 
-	if _, err := responsesCol.DeleteMany(c.Context(), bson.M{"formId": oid}); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "form deleted, but failed to delete responses"})
+	res, err := col.UpdateOne(c.Context(),
+		bson.M{"_id": respID, "formId": formID, "deletedAt": nil},
+		bson.M{"$set": bson.M{"deletedAt": time.Now().UTC().Truncate(time.Millisecond)}},
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to delete response"})
 	}
+	if res.MatchedCount == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "response not found"})
+	}
+	// a trashed response frees its slot under the form's cap
+	adjustResponseCount(c.Context(), c.Locals("forms").(*mongo.Collection), formID, -1)
+	analytics.MarkStale(c.Context(), c.Locals("analytics").(*mongo.Collection), formID)
+	rtNotify(formID.Hex())
 
-	// No content returned on success.
 	return c.SendStatus(204)
 }
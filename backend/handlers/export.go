@@ -0,0 +1,260 @@
+// Streaming export of a form's responses, one column per field.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// exportTimeout bounds how long a single streamed export may hold its cursor.
+const exportTimeout = 30 * time.Minute
+
+// Column kinds.
+const (
+	colResponseID  = "responseId"
+	colSubmittedAt = "submittedAt"
+	colField       = "field"
+	colOption      = "option"
+)
+
+// Checkbox flattening modes.
+const (
+	checkboxJoined = "joined"
+	checkboxOneHot = "onehot"
+)
+
+type exportOptions struct {
+	checkboxMode   string
+	multiDelimiter string
+}
+
+// exportColumn is one output column: submission metadata, a field's answer,
+// or (one-hot mode) whether a single checkbox option was selected. header is
+// the human-readable label; key is a stable machine name for typed formats.
+type exportColumn struct {
+	header string
+	key    string
+	kind   string
+	field  models.Field
+	option string
+}
+
+// exportContext is what a format needs beyond the rows themselves.
+type exportContext struct {
+	form    models.Form
+	opt     exportOptions
+	summary analytics.Result // analytics snapshot, for formats that embed one
+}
+
+// rowWriter encodes rows for one export format.
+type rowWriter interface {
+	writeHeader(cols []exportColumn) error
+	writeRow(cols []exportColumn, vals []interface{}) error
+	close() error
+}
+
+// parseExportOptions reads checkbox flattening settings from the query string.
+func parseExportOptions(c *fiber.Ctx) (exportOptions, error) {
+	opt := exportOptions{
+		checkboxMode:   c.Query("checkbox", checkboxJoined),
+		multiDelimiter: c.Query("multiDelimiter", ";"),
+	}
+	if opt.checkboxMode != checkboxJoined && opt.checkboxMode != checkboxOneHot {
+		return opt, fmt.Errorf("checkbox must be %q or %q", checkboxJoined, checkboxOneHot)
+	}
+	return opt, nil
+}
+
+// buildExportColumns lays out metadata columns followed by the form's fields
+// in definition order, headed by their labels.
+func buildExportColumns(form models.Form, opt exportOptions) []exportColumn {
+	cols := []exportColumn{
+		{header: "Response ID", key: "responseId", kind: colResponseID},
+		{header: "Submitted At", key: "submittedAt", kind: colSubmittedAt},
+	}
+	for _, f := range form.Fields {
+		if f.Type == "checkbox" && opt.checkboxMode == checkboxOneHot {
+			for _, o := range f.Options {
+				cols = append(cols, exportColumn{header: f.Label + ": " + o, key: f.ID + "." + o, kind: colOption, field: f, option: o})
+			}
+			continue
+		}
+		cols = append(cols, exportColumn{header: f.Label, key: f.ID, kind: colField, field: f})
+	}
+	return cols
+}
+
+// exportValues extracts typed cell values for r: string, float64, bool,
+// time.Time, []string (checkbox selections) or nil for unanswered fields.
+// Flat formats join []string with exportOptions.multiDelimiter.
+func exportValues(cols []exportColumn, r *models.Response) []interface{} {
+	vals := make([]interface{}, len(cols))
+	for i, col := range cols {
+		switch col.kind {
+		case colResponseID:
+			vals[i] = r.ID.Hex()
+		case colSubmittedAt:
+			vals[i] = r.SubmittedAt.UTC()
+		case colOption:
+			vals[i] = analytics.ContainsString(analytics.AnswerStrings(r.Answers[col.field.ID]), col.option)
+		case colField:
+			v, ok := r.Answers[col.field.ID]
+			if !ok || v == nil {
+				continue
+			}
+			switch col.field.Type {
+			case "rating":
+				if n, ok := analytics.ToFloat(v); ok {
+					vals[i] = n
+				}
+			case "checkbox":
+				vals[i] = analytics.AnswerStrings(v)
+			default:
+				vals[i] = fmt.Sprint(v)
+			}
+		}
+	}
+	return vals
+}
+
+// streamExport writes every live response of form through w, reading from a
+// cursor so memory stays flat regardless of response count.
+func streamExport(ctx context.Context, cur *mongo.Cursor, x exportContext, w rowWriter) error {
+	cols := buildExportColumns(x.form, x.opt)
+	if err := w.writeHeader(cols); err != nil {
+		return err
+	}
+	for cur.Next(ctx) {
+		var r models.Response
+		if err := cur.Decode(&r); err != nil {
+			return err
+		}
+		if err := w.writeRow(cols, exportValues(cols, &r)); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	return w.close()
+}
+
+// exportFilter matches a form's live responses, optionally limited to
+// submissions in [from, to) and to the compiled answer conditions.
+func exportFilter(formID primitive.ObjectID, from, to *time.Time, conds []bson.M) bson.M {
+	filter := responseMatch(formID)
+	if from != nil || to != nil {
+		rng := bson.M{}
+		if from != nil {
+			rng["$gte"] = *from
+		}
+		if to != nil {
+			rng["$lt"] = *to
+		}
+		filter["submittedAt"] = rng
+	}
+	if len(conds) > 0 {
+		and := make(bson.A, len(conds))
+		for i, cond := range conds {
+			and[i] = cond
+		}
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// openResponseCursor opens a submittedAt-ordered cursor over the responses
+// matching filter.
+func openResponseCursor(ctx context.Context, col *mongo.Collection, filter bson.M) (*mongo.Cursor, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "submittedAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(1000)
+	return col.Find(ctx, filter, opts)
+}
+
+// GET /forms/:id/responses/export?format=csv|ndjson|xlsx|parquet
+func ExportResponses(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	respCol := c.Locals("responses").(*mongo.Collection)
+
+	format := c.Query("format", "csv")
+	enc, ok := exportFormats[format]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "unsupported format: " + format})
+	}
+	opt, err := parseExportOptions(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	form, status, err := loadForm(c.Context(), formsCol, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	x := exportContext{form: form, opt: opt}
+	if enc.withSummary {
+		if x.summary, err = analytics.Snapshot(c.Context(), c.Locals("db").(*mongo.Database), form, nil, false); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to compute analytics"})
+		}
+	}
+
+	// The body is written after the handler returns, so the cursor must not
+	// depend on the request context.
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	cur, err := openResponseCursor(ctx, respCol, responseMatch(form.ID))
+	if err != nil {
+		cancel()
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read responses"})
+	}
+
+	name := form.Slug
+	if name == "" {
+		name = form.ID.Hex()
+	}
+	c.Set(fiber.HeaderContentType, enc.contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-responses.%s"`, name, enc.ext))
+	c.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		defer cancel()
+		defer cur.Close(ctx)
+		if err := streamExport(ctx, cur, x, enc.open(bw, x)); err != nil {
+			log.Printf("export: form %s (%s) failed: %v", form.ID.Hex(), format, err)
+		}
+		_ = bw.Flush()
+	})
+	return nil
+}
+
+// exportFormat describes how to encode one export format.
+type exportFormat struct {
+	contentType string
+	ext         string
+	withSummary bool
+	open        func(w io.Writer, x exportContext) rowWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":    {contentType: "text/csv; charset=utf-8", ext: "csv", open: newCSVRowWriter},
+	"ndjson": {contentType: "application/x-ndjson", ext: "ndjson", open: newNDJSONRowWriter},
+	"xlsx": {
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		ext:         "xlsx",
+		withSummary: true,
+		open:        newXLSXRowWriter,
+	},
+	"parquet": {contentType: "application/vnd.apache.parquet", ext: "parquet", open: newParquetRowWriter},
+}
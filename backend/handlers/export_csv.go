@@ -0,0 +1,88 @@
+// CSV encoding for response exports.
+
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvFlushEvery bounds how many rows are buffered before reaching the client.
+const csvFlushEvery = 500
+
+type csvRowWriter struct {
+	w     *csv.Writer
+	delim string
+	rows  int
+	rec   []string
+}
+
+func newCSVRowWriter(w io.Writer, x exportContext) rowWriter {
+	return &csvRowWriter{w: csv.NewWriter(w), delim: x.opt.multiDelimiter}
+}
+
+func (cw *csvRowWriter) writeHeader(cols []exportColumn) error {
+	hdr := make([]string, len(cols))
+	for i, col := range cols {
+		hdr[i] = csvSafe(col.header)
+	}
+	cw.rec = make([]string, len(cols))
+	return cw.w.Write(hdr)
+}
+
+func (cw *csvRowWriter) writeRow(_ []exportColumn, vals []interface{}) error {
+	for i, v := range vals {
+		if ss, ok := v.([]string); ok {
+			v = strings.Join(ss, cw.delim)
+		}
+		cw.rec[i] = csvCell(v)
+	}
+	if err := cw.w.Write(cw.rec); err != nil {
+		return err
+	}
+	cw.rows++
+	if cw.rows%csvFlushEvery == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvRowWriter) close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func csvCell(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return csvSafe(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		if t {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// csvSafe neutralises respondent text that spreadsheets would run as a formula.
+func csvSafe(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,406 @@
+// Asynchronous export jobs: enqueue, poll, signed downloads, and the worker
+// that writes artifacts to local storage and cleans them up after retention.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const (
+	defaultExportRetentionHours = 24
+	exportLinkTTL               = time.Hour
+	// exportRequeueGrace is added to exportTimeout before a running job is
+	// presumed orphaned, so a worker at the end of its timeout can finish.
+	exportRequeueGrace = 5 * time.Minute
+	rtEventExport      = "export"
+)
+
+// exportWake nudges the in-process worker when a job is enqueued, so it
+// doesn't wait for the next poll.
+var exportWake = make(chan struct{}, 1)
+
+var (
+	signingKeyOnce sync.Once
+	signingKey     []byte
+)
+
+// exportSigningKey signs download links. EXPORT_SIGNING_KEY is preferred;
+// API_KEY is the fallback; without either, links only verify on this process.
+// Read lazily so values loaded from .env in main are seen.
+func exportSigningKey() []byte {
+	signingKeyOnce.Do(func() {
+		switch {
+		case os.Getenv("EXPORT_SIGNING_KEY") != "":
+			signingKey = []byte(os.Getenv("EXPORT_SIGNING_KEY"))
+		case os.Getenv("API_KEY") != "":
+			signingKey = []byte(os.Getenv("API_KEY"))
+		default:
+			signingKey = []byte(randomHex(32))
+		}
+	})
+	return signingKey
+}
+
+// exportDir is where artifacts are written (EXPORT_DIR, default a temp subdir).
+// With several instances it must be a volume shared by all of them.
+func exportDir() string {
+	if d := os.Getenv("EXPORT_DIR"); d != "" {
+		return d
+	}
+	return filepath.Join(os.TempDir(), "form-exports")
+}
+
+// exportRetention reads EXPORT_RETENTION_HOURS, falling back to 24 hours.
+func exportRetention() time.Duration {
+	h, err := strconv.Atoi(os.Getenv("EXPORT_RETENTION_HOURS"))
+	if err != nil || h < 1 {
+		h = defaultExportRetentionHours
+	}
+	return time.Duration(h) * time.Hour
+}
+
+func signExportLink(jobID string, expires int64) string {
+	mac := hmac.New(sha256.New, exportSigningKey())
+	fmt.Fprintf(mac, "%s:%d", jobID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// exportJobView adds a signed, expiring download URL to finished jobs.
+func exportJobView(job models.ExportJob) fiber.Map {
+	out := fiber.Map{"job": job}
+	if job.Status == models.ExportDone && job.ExpiresAt != nil {
+		expires := time.Now().Add(exportLinkTTL)
+		if job.ExpiresAt.Before(expires) {
+			expires = *job.ExpiresAt
+		}
+		id := job.ID.Hex()
+		out["downloadUrl"] = fmt.Sprintf("/exports/%s/download?expires=%d&sig=%s",
+			id, expires.Unix(), signExportLink(id, expires.Unix()))
+		out["downloadExpiresAt"] = expires
+	}
+	return out
+}
+
+// POST /forms/:id/exports
+func CreateExportJob(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	jobsCol := c.Locals("exports").(*mongo.Collection)
+
+	var body struct {
+		Format         string     `json:"format"`
+		Checkbox       string     `json:"checkbox"`
+		MultiDelimiter string     `json:"multiDelimiter"`
+		From           *time.Time `json:"from"`
+		To             *time.Time `json:"to"`
+		Q              string     `json:"q"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if body.Format == "" {
+		body.Format = "csv"
+	}
+	if _, ok := exportFormats[body.Format]; !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "unsupported format: " + body.Format})
+	}
+	if body.Checkbox == "" {
+		body.Checkbox = checkboxJoined
+	}
+	if body.Checkbox != checkboxJoined && body.Checkbox != checkboxOneHot {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("checkbox must be %q or %q", checkboxJoined, checkboxOneHot)})
+	}
+	if body.MultiDelimiter == "" {
+		body.MultiDelimiter = ";"
+	}
+	if body.From != nil && body.To != nil && !body.To.After(*body.From) {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be after from"})
+	}
+
+	form, status, err := loadForm(c.Context(), formsCol, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	// q uses the ListResponses filter language; the worker compiles it again
+	// against the form as it is when the job runs.
+	if _, err := compileResponseQuery(form, body.Q); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	job := models.ExportJob{
+		FormID:         form.ID,
+		Format:         body.Format,
+		CheckboxMode:   body.Checkbox,
+		MultiDelimiter: body.MultiDelimiter,
+		From:           body.From,
+		To:             body.To,
+		Q:              body.Q,
+		Status:         models.ExportQueued,
+		CreatedAt:      time.Now(),
+	}
+	res, err := jobsCol.InsertOne(c.Context(), job)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to enqueue export"})
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		job.ID = oid
+	}
+
+	select {
+	case exportWake <- struct{}{}:
+	default:
+	}
+	return c.Status(202).JSON(exportJobView(job))
+}
+
+// GET /exports/:jobId
+func GetExportJob(c *fiber.Ctx) error {
+	jobsCol := c.Locals("exports").(*mongo.Collection)
+
+	oid, err := primitive.ObjectIDFromHex(c.Params("jobId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	var job models.ExportJob
+	if err := jobsCol.FindOne(c.Context(), bson.M{"_id": oid}).Decode(&job); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "export not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to fetch export"})
+	}
+	return c.JSON(exportJobView(job))
+}
+
+// GET /exports/:jobId/download?expires=&sig=
+// Public: the signed link is the credential.
+func DownloadExport(c *fiber.Ctx) error {
+	jobsCol := c.Locals("exports").(*mongo.Collection)
+
+	id := c.Params("jobId")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return c.Status(403).JSON(fiber.Map{"error": "download link expired"})
+	}
+	if !hmac.Equal([]byte(c.Query("sig")), []byte(signExportLink(id, expires))) {
+		return c.Status(403).JSON(fiber.Map{"error": "invalid download link"})
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+	var job models.ExportJob
+	if err := jobsCol.FindOne(c.Context(), bson.M{"_id": oid, "status": models.ExportDone}).Decode(&job); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "export not available"})
+	}
+
+	enc := exportFormats[job.Format]
+	c.Set(fiber.HeaderContentType, enc.contentType)
+	return c.Download(job.FilePath, fmt.Sprintf("%s-responses.%s", job.FormID.Hex(), enc.ext))
+}
+
+// RunExportWorker claims queued jobs one at a time, writes their artifacts
+// and removes expired ones. Claims are atomic, so several instances may run
+// workers against the same collection. Blocks until ctx ends.
+func RunExportWorker(ctx context.Context, db *mongo.Database, interval time.Duration) {
+	jobsCol := db.Collection("exports")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if err := os.MkdirAll(exportDir(), 0o750); err != nil {
+		log.Printf("export worker: cannot create %s: %v", exportDir(), err)
+	}
+
+	for {
+		requeueStaleExports(ctx, jobsCol)
+		for runNextExport(ctx, db, jobsCol) {
+		}
+		cleanupExpiredExports(ctx, jobsCol)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-exportWake:
+		}
+	}
+}
+
+// runNextExport claims and runs one queued job; false when the queue is empty.
+func runNextExport(ctx context.Context, db *mongo.Database, jobsCol *mongo.Collection) bool {
+	now := time.Now()
+	var job models.ExportJob
+	err := jobsCol.FindOneAndUpdate(ctx,
+		bson.M{"status": models.ExportQueued},
+		bson.M{"$set": bson.M{"status": models.ExportRunning, "startedAt": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("export worker: claim failed: %v", err)
+		}
+		return false
+	}
+
+	path, size, runErr := writeExportArtifact(ctx, db, job)
+
+	set := bson.M{"finishedAt": time.Now()}
+	event := fiber.Map{"jobId": job.ID.Hex(), "format": job.Format}
+	if runErr != nil {
+		log.Printf("export worker: job %s failed: %v", job.ID.Hex(), runErr)
+		set["status"] = models.ExportFailed
+		set["error"] = runErr.Error()
+		event["status"] = models.ExportFailed
+	} else {
+		set["status"] = models.ExportDone
+		set["filePath"] = path
+		set["size"] = size
+		set["expiresAt"] = time.Now().Add(exportRetention())
+		event["status"] = models.ExportDone
+	}
+	// Only the worker holding the current claim may finish the job; a
+	// requeued job belongs to whoever claimed it since.
+	res, err := jobsCol.UpdateOne(ctx,
+		bson.M{"_id": job.ID, "status": models.ExportRunning, "startedAt": job.StartedAt},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		log.Printf("export worker: record job %s failed: %v", job.ID.Hex(), err)
+	} else if res.MatchedCount == 0 {
+		log.Printf("export worker: job %s was reclaimed, discarding this run", job.ID.Hex())
+		if path != "" {
+			_ = os.Remove(path)
+		}
+		return true
+	}
+	rtPublish(job.FormID.Hex(), rtEvent{Type: rtEventExport, Data: event})
+	return true
+}
+
+// writeExportArtifact streams the job's responses to a file in exportDir.
+func writeExportArtifact(ctx context.Context, db *mongo.Database, job models.ExportJob) (string, int64, error) {
+	enc, ok := exportFormats[job.Format]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported format: %s", job.Format)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	form, _, err := loadForm(ctx, db.Collection("forms"), job.FormID.Hex())
+	if err != nil {
+		return "", 0, err
+	}
+	x := exportContext{
+		form: form,
+		opt:  exportOptions{checkboxMode: job.CheckboxMode, multiDelimiter: job.MultiDelimiter},
+	}
+	conds, err := compileResponseQuery(form, job.Q)
+	if err != nil {
+		return "", 0, fmt.Errorf("filter no longer applies to the form: %w", err)
+	}
+	filter := exportFilter(form.ID, job.From, job.To, conds)
+	filtered := job.From != nil || job.To != nil || len(conds) > 0
+
+	respCol := db.Collection("responses")
+	if enc.withSummary {
+		if x.summary, err = analytics.Snapshot(ctx, db, form, filter, filtered); err != nil {
+			return "", 0, err
+		}
+	}
+
+	cur, err := openResponseCursor(ctx, respCol, filter)
+	if err != nil {
+		return "", 0, err
+	}
+	defer cur.Close(ctx)
+
+	// The claim time keeps a reclaimed job's run from writing over this one.
+	path := filepath.Join(exportDir(), fmt.Sprintf("%s-%d.%s", job.ID.Hex(), job.StartedAt.UnixMilli(), enc.ext))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", 0, err
+	}
+	bw := bufio.NewWriter(f)
+	err = streamExport(ctx, cur, x, enc.open(bw, x))
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+// requeueStaleExports returns jobs orphaned by a crashed worker to the queue.
+// A live worker gives up at exportTimeout, so jobs older than that plus the
+// grace period have no one running them.
+func requeueStaleExports(ctx context.Context, jobsCol *mongo.Collection) {
+	if _, err := jobsCol.UpdateMany(ctx,
+		bson.M{"status": models.ExportRunning, "startedAt": bson.M{"$lt": time.Now().Add(-exportTimeout - exportRequeueGrace)}},
+		bson.M{"$set": bson.M{"status": models.ExportQueued}, "$unset": bson.M{"startedAt": ""}},
+	); err != nil {
+		log.Printf("export worker: requeue failed: %v", err)
+	}
+}
+
+// cleanupExpiredExports deletes artifacts past retention and marks their jobs expired.
+func cleanupExpiredExports(ctx context.Context, jobsCol *mongo.Collection) {
+	cur, err := jobsCol.Find(ctx, bson.M{
+		"status":    models.ExportDone,
+		"expiresAt": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		log.Printf("export worker: cleanup scan failed: %v", err)
+		return
+	}
+	var jobs []models.ExportJob
+	if err := cur.All(ctx, &jobs); err != nil {
+		log.Printf("export worker: cleanup read failed: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("export worker: remove %s failed: %v", job.FilePath, err)
+			continue
+		}
+		if _, err := jobsCol.UpdateOne(ctx,
+			bson.M{"_id": job.ID},
+			bson.M{"$set": bson.M{"status": models.ExportExpired}, "$unset": bson.M{"filePath": ""}},
+		); err != nil {
+			log.Printf("export worker: expire job %s failed: %v", job.ID.Hex(), err)
+		}
+	}
+}
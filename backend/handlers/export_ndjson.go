@@ -0,0 +1,41 @@
+// NDJSON encoding for response exports: one JSON object per response.
+
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+type ndjsonRowWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+	obj map[string]interface{}
+}
+
+func newNDJSONRowWriter(w io.Writer, _ exportContext) rowWriter {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	return &ndjsonRowWriter{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+// NDJSON has no header line; keys come from the columns on each row.
+func (nw *ndjsonRowWriter) writeHeader(cols []exportColumn) error {
+	nw.obj = make(map[string]interface{}, len(cols))
+	return nil
+}
+
+// Keys are the column keys (field IDs); unanswered fields are null.
+func (nw *ndjsonRowWriter) writeRow(cols []exportColumn, vals []interface{}) error {
+	for i, col := range cols {
+		nw.obj[col.key] = vals[i]
+	}
+	return nw.enc.Encode(nw.obj)
+}
+
+func (nw *ndjsonRowWriter) close() error {
+	return nw.bw.Flush()
+}
@@ -0,0 +1,116 @@
+// Parquet encoding for response exports, typed from the form's field definitions.
+
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+)
+
+// parquetRowGroupSize caps rows buffered in memory before a row group is flushed.
+const parquetRowGroupSize = 50_000
+
+type parquetRowWriter struct {
+	out   io.Writer
+	delim string
+	w     *parquet.Writer
+	// leaf column index and max definition level per export column
+	index []int
+	level []int
+	order []int // export columns sorted by parquet column index
+	row   []parquet.Row
+}
+
+func newParquetRowWriter(w io.Writer, x exportContext) rowWriter {
+	return &parquetRowWriter{out: w, delim: x.opt.multiDelimiter}
+}
+
+// parquetNode maps an export column to its parquet type: ratings are doubles,
+// submission time is a UTC timestamp, one-hot options are booleans and
+// everything else is a UTF-8 string. Answers are optional.
+func parquetNode(col exportColumn) parquet.Node {
+	switch col.kind {
+	case colResponseID:
+		return parquet.String()
+	case colSubmittedAt:
+		return parquet.Timestamp(parquet.Millisecond)
+	case colOption:
+		return parquet.Leaf(parquet.BooleanType)
+	}
+	if col.field.Type == "rating" {
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	}
+	return parquet.Optional(parquet.String())
+}
+
+func (pw *parquetRowWriter) writeHeader(cols []exportColumn) error {
+	group := parquet.Group{}
+	for _, col := range cols {
+		if _, dup := group[col.key]; dup {
+			return fmt.Errorf("duplicate parquet column %q", col.key)
+		}
+		group[col.key] = parquetNode(col)
+	}
+	schema := parquet.NewSchema("response", group)
+
+	pw.index = make([]int, len(cols))
+	pw.level = make([]int, len(cols))
+	pw.order = make([]int, len(cols))
+	for i, col := range cols {
+		leaf, ok := schema.Lookup(col.key)
+		if !ok {
+			return fmt.Errorf("parquet column %q not in schema", col.key)
+		}
+		pw.index[i] = leaf.ColumnIndex
+		pw.level[i] = leaf.MaxDefinitionLevel
+		pw.order[i] = i
+	}
+	sort.Slice(pw.order, func(a, b int) bool { return pw.index[pw.order[a]] < pw.index[pw.order[b]] })
+
+	pw.w = parquet.NewWriter(pw.out,
+		schema,
+		parquet.Compression(&snappy.Codec{}),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.CreatedBy("dune-forms", "", ""),
+	)
+	pw.row = make([]parquet.Row, 1)
+	return nil
+}
+
+func (pw *parquetRowWriter) writeRow(_ []exportColumn, vals []interface{}) error {
+	row := pw.row[0][:0]
+	for _, i := range pw.order {
+		var v parquet.Value
+		switch t := vals[i].(type) {
+		case nil:
+			row = append(row, parquet.NullValue().Level(0, 0, pw.index[i]))
+			continue
+		case string:
+			v = parquet.ByteArrayValue([]byte(t))
+		case []string:
+			v = parquet.ByteArrayValue([]byte(strings.Join(t, pw.delim)))
+		case float64:
+			v = parquet.DoubleValue(t)
+		case bool:
+			v = parquet.BooleanValue(t)
+		case time.Time:
+			v = parquet.Int64Value(t.UnixMilli())
+		default:
+			v = parquet.ByteArrayValue([]byte(fmt.Sprint(t)))
+		}
+		row = append(row, v.Level(0, pw.level[i], pw.index[i]))
+	}
+	pw.row[0] = row
+	_, err := pw.w.WriteRows(pw.row)
+	return err
+}
+
+func (pw *parquetRowWriter) close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,118 @@
+// XLSX encoding for response exports: a responses sheet plus an analytics summary sheet.
+
+package handlers
+
+import (
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxResponsesSheet = "Responses"
+	xlsxSummarySheet   = "Summary"
+)
+
+// xlsxRowWriter streams rows into the workbook's sheet XML (spilling to a
+// temp file when large); the zip archive is produced on close.
+type xlsxRowWriter struct {
+	out   io.Writer
+	x     exportContext
+	f     *excelize.File
+	sw    *excelize.StreamWriter
+	row   int
+	cells []interface{}
+	err   error
+}
+
+func newXLSXRowWriter(w io.Writer, x exportContext) rowWriter {
+	xw := &xlsxRowWriter{out: w, x: x, f: excelize.NewFile()}
+	if err := xw.f.SetSheetName("Sheet1", xlsxResponsesSheet); err != nil {
+		xw.err = err
+		return xw
+	}
+	xw.sw, xw.err = xw.f.NewStreamWriter(xlsxResponsesSheet)
+	return xw
+}
+
+func (xw *xlsxRowWriter) writeHeader(cols []exportColumn) error {
+	if xw.err != nil {
+		return xw.err
+	}
+	hdr := make([]interface{}, len(cols))
+	for i, col := range cols {
+		hdr[i] = col.header
+	}
+	xw.cells = make([]interface{}, len(cols))
+	xw.row = 1
+	return xw.sw.SetRow("A1", hdr, excelize.RowOpts{Height: 18})
+}
+
+func (xw *xlsxRowWriter) writeRow(_ []exportColumn, vals []interface{}) error {
+	for i, v := range vals {
+		if ss, ok := v.([]string); ok {
+			v = strings.Join(ss, xw.x.opt.multiDelimiter)
+		}
+		xw.cells[i] = v
+	}
+	xw.row++
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.sw.SetRow(cell, xw.cells)
+}
+
+func (xw *xlsxRowWriter) close() error {
+	defer xw.f.Close()
+	if err := xw.sw.Flush(); err != nil {
+		return err
+	}
+	if err := xw.writeSummary(); err != nil {
+		return err
+	}
+	return xw.f.Write(xw.out)
+}
+
+// writeSummary renders the analytics snapshot with field labels instead of IDs.
+func (xw *xlsxRowWriter) writeSummary() error {
+	if _, err := xw.f.NewSheet(xlsxSummarySheet); err != nil {
+		return err
+	}
+	sw, err := xw.f.NewStreamWriter(xlsxSummarySheet)
+	if err != nil {
+		return err
+	}
+
+	fields := xw.x.summary.Fields
+
+	rows := [][]interface{}{
+		{"Form", xw.x.form.Title},
+		{"Total responses", xw.x.summary.TotalResponses},
+		{},
+		{"Rating field", "Responses", "Average", "Min", "Max"},
+	}
+	for _, f := range xw.x.form.Fields {
+		if r := fields[f.ID].Rating; r != nil && r.Count > 0 {
+			rows = append(rows, []interface{}{f.Label, r.Count, *r.Avg, *r.Min, *r.Max})
+		}
+	}
+	rows = append(rows, []interface{}{}, []interface{}{"Field", "Option", "Count", "Share"})
+	for _, f := range xw.x.form.Fields {
+		for _, o := range fields[f.ID].Options {
+			rows = append(rows, []interface{}{f.Label, o.Option, o.Count, o.Share})
+		}
+	}
+
+	for i, r := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetRow(cell, r); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
@@ -27,37 +27,47 @@ func CreateForm(c *fiber.Ctx) error {
 	if body.Title == "" || len(body.Fields) == 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "title and at least one field are required"})
 	}
-	for _, f := range body.Fields {
-		if f.ID == "" || f.Type == "" || f.Label == "" {
-			return c.Status(400).JSON(fiber.Map{"error": "each field requires id, type, label"})
-		}
-		if (f.Type == "mc" || f.Type == "checkbox") && len(f.Options) == 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "mc/checkbox require options"})
-		}
-		if f.Type == "rating" && (f.Min == nil || f.Max == nil || *f.Min >= *f.Max) {
-			return c.Status(400).JSON(fiber.Map{"error": "rating needs valid min/max"})
-		}
+	if err := validateFieldDefs(body.Fields); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// field IDs
-	seen := make(map[string]struct{}, len(body.Fields))
-	for _, f := range body.Fields {
-		if _, dup := seen[f.ID]; dup {
-			return c.Status(400).JSON(fiber.Map{"error": "duplicate field id: " + f.ID})
+	generated := body.Slug == ""
+	if !generated {
+		if err := validateSlug(body.Slug); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		owner, err := slugOwner(c.Context(), col, body.Slug)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to check slug"})
+		}
+		if !owner.IsZero() {
+			return slugConflict(c, col, body.Slug)
 		}
-		seen[f.ID] = struct{}{}
 	}
 
 	now := time.Now()
-	if body.Slug == "" {
-		body.Slug = primitive.NewObjectID().Hex()[:8]
-	}
+	body.ID = primitive.NilObjectID
+	body.PreviousSlugs = nil
+	body.DeletedAt = nil
 	body.Status = "draft"
+	body.Version = 1
+	body.ResponseCount = 0
 	body.CreatedAt = now
 	body.UpdatedAt = now
 
-	res, err := col.InsertOne(c.Context(), body)
+	var (
+		res *mongo.InsertOneResult
+		err error
+	)
+	if generated {
+		res, err = insertWithGeneratedSlug(c.Context(), col, &body)
+	} else {
+		res, err = col.InsertOne(c.Context(), body)
+	}
 	if err != nil {
+		if !generated && mongo.IsDuplicateKeyError(err) {
+			return slugConflict(c, col, body.Slug)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "failed to save form"})
 	}
 
@@ -77,7 +87,7 @@ func GetForm(c *fiber.Ctx) error {
 	}
 
 	var form models.Form
-	if err := col.FindOne(c.Context(), bson.M{"_id": oid}).Decode(&form); err != nil {
+	if err := col.FindOne(c.Context(), bson.M{"_id": oid, "deletedAt": nil}).Decode(&form); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return c.Status(404).JSON(fiber.Map{"error": "form not found"})
 		}
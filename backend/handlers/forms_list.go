@@ -1,46 +1,35 @@
-// ListForms returns paginated forms filtered by status, sorted by last update.
+// ListForms returns forms filtered by status, paged by cursor or page number.
 
 package handlers
 
 import (
-	"strconv"
-
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
 )
 
-// returns paginated forms filtered by status, sorted by last update.
+// returns forms filtered by status, sorted by last update unless ?sort= is given.
 func ListForms(c *fiber.Ctx) error {
 	col := c.Locals("forms").(*mongo.Collection)
 
-	// query params
 	status := c.Query("status", "")
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "20")
-
-	page, _ := strconv.Atoi(pageStr)
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(limitStr)
-	if limit < 1 || limit > 100 {
-		limit = 20
+	spec, err := parsePageSpec(c, []string{"updatedAt", "createdAt", "title"}, 20, 100)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	skip := int64((page - 1) * limit)
 
-	filter := bson.M{}
-	if status != "" {
+	filter := bson.M{"deletedAt": nil}
+	if status == models.StatusOpen {
+		// legacy "published" forms are open too
+		filter["status"] = bson.M{"$in": bson.A{models.StatusOpen, models.StatusPublished}}
+	} else if status != "" {
 		filter["status"] = status
 	}
 
-	opts := options.Find().
-		SetSort(bson.D{{Key: "updatedAt", Value: -1}}).
-		SetSkip(skip).
-		SetLimit(int64(limit))
-
-	cur, err := col.Find(c.Context(), filter, opts)
+	pageFilter, opts := spec.apply(filter)
+	cur, err := col.Find(c.Context(), pageFilter, opts)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to list forms"})
 	}
@@ -49,15 +38,12 @@ func ListForms(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to read forms"})
 	}
 
-	total, err := col.CountDocuments(c.Context(), filter)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to count forms"})
+	var total int64
+	if spec.withTotal {
+		if total, err = col.CountDocuments(c.Context(), filter); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to count forms"})
+		}
 	}
 
-	return c.JSON(fiber.Map{
-		"items": out,
-		"page":  page,
-		"limit": limit,
-		"total": total,
-	})
+	return c.JSON(spec.result(out, total))
 }
@@ -0,0 +1,330 @@
+// Respondent funnel: view/start/field events recorded from public forms and
+// the views → starts → per-field reached → completed analytics built on them.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// sessionHeader carries the client-generated respondent session ID on the
+// public form fetch, events and the submission itself.
+const sessionHeader = "X-Session-Id"
+
+var sessionPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// requestSession returns the session ID from the header or ?session=, or ""
+// when absent or malformed.
+func requestSession(c *fiber.Ctx) string {
+	s := c.Get(sessionHeader)
+	if s == "" {
+		s = c.Query("session")
+	}
+	if !sessionPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// recordFormEvent stores an event without failing the caller's request. A
+// session's view and start are kept once, at their first occurrence, so
+// reloads and repeated starts don't inflate the funnel.
+func recordFormEvent(ctx context.Context, col *mongo.Collection, ev models.FormEvent) {
+	var err error
+	if ev.SessionID != "" && (ev.Type == models.EventView || ev.Type == models.EventStart) {
+		_, err = col.UpdateOne(ctx,
+			bson.M{"formId": ev.FormID, "sessionId": ev.SessionID, "type": ev.Type},
+			bson.M{"$setOnInsert": bson.M{"at": ev.At}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			err = nil // a concurrent request from the session recorded it
+		}
+	} else {
+		_, err = col.InsertOne(ctx, ev)
+	}
+	if err != nil {
+		log.Printf("funnel: record %s for form %s failed: %v", ev.Type, ev.FormID.Hex(), err)
+	}
+}
+
+// POST /public/forms/:slug/events
+//
+// Body: {"type": "start"|"field", "fieldId": "..."}; the session comes from
+// the X-Session-Id header. Views are recorded by GET /public/forms/:slug.
+func RecordFormEvent(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	eventsCol := c.Locals("events").(*mongo.Collection)
+
+	session := requestSession(c)
+	if session == "" {
+		return c.Status(400).JSON(fiber.Map{"error": sessionHeader + " header required (8-64 chars of [A-Za-z0-9_-])"})
+	}
+
+	var body struct {
+		Type    string `json:"type"`
+		FieldID string `json:"fieldId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	switch body.Type {
+	case models.EventStart:
+		body.FieldID = ""
+	case models.EventField:
+		if body.FieldID == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "fieldId required for field events"})
+		}
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": `type must be "start" or "field"`})
+	}
+
+	var form struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Status   string             `bson:"status"`
+		OpensAt  *time.Time         `bson:"opensAt"`
+		ClosesAt *time.Time         `bson:"closesAt"`
+		Fields   []struct {
+			ID string `bson:"id"`
+		} `bson:"fields"`
+	}
+	err := formsCol.FindOne(c.Context(),
+		bson.M{"slug": c.Params("slug"), "status": bson.M{"$in": liveStatuses}, "deletedAt": nil},
+		options.FindOne().SetProjection(bson.M{"status": 1, "opensAt": 1, "closesAt": 1, "fields.id": 1}),
+	).Decode(&form)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "form not found or unpublished"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load form"})
+	}
+	if effectiveStatus(form.Status, form.OpensAt, form.ClosesAt, time.Now()) != models.StatusOpen {
+		return c.Status(403).JSON(fiber.Map{"error": "form is not accepting responses"})
+	}
+	if body.Type == models.EventField {
+		known := false
+		for _, f := range form.Fields {
+			known = known || f.ID == body.FieldID
+		}
+		if !known {
+			return c.Status(400).JSON(fiber.Map{"error": "unknown field: " + body.FieldID})
+		}
+	}
+
+	recordFormEvent(c.Context(), eventsCol, models.FormEvent{
+		FormID:    form.ID,
+		SessionID: session,
+		Type:      body.Type,
+		FieldID:   body.FieldID,
+		At:        time.Now(),
+	})
+	return c.SendStatus(204)
+}
+
+// funnelField is one step of the per-field funnel. Reached counts started
+// sessions that got at least as far as this field; Dropped those that
+// reached it last and never submitted.
+type funnelField struct {
+	ID          string  `json:"id"`
+	Label       string  `json:"label"`
+	Type        string  `json:"type"`
+	Reached     int64   `json:"reached"`
+	ReachedRate float64 `json:"reachedRate"`
+	Dropped     int64   `json:"dropped"`
+}
+
+func rate(n, of int64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+// GET /forms/:id/analytics/funnel?from=&to=
+func FormFunnel(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	respCol := c.Locals("responses").(*mongo.Collection)
+	eventsCol := c.Locals("events").(*mongo.Collection)
+
+	form, status, err := loadForm(c.Context(), formsCol, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	from, to, err := analyticsRange(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	match := bson.M{"formId": form.ID}
+	completionMatch := responseMatch(form.ID)
+	if from != nil || to != nil {
+		rng := bson.M{}
+		if from != nil {
+			rng["$gte"] = *from
+		}
+		if to != nil {
+			rng["$lt"] = *to
+		}
+		match["at"] = rng
+		completionMatch["submittedAt"] = rng
+	}
+
+	fieldIDs := bson.A{}
+	for _, f := range form.Fields {
+		fieldIDs = append(fieldIDs, f.ID)
+	}
+
+	// One row per session: views, first start and the furthest field reached
+	// (as an index into the form's fields), joined with its submission.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$ifNull": bson.A{"$sessionId", ""}},
+			"views": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", models.EventView}}, 1, 0}}},
+			"started": bson.M{"$min": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$type", models.EventStart}}, "$at", nil,
+			}}},
+			"maxField": bson.M{"$max": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$type", models.EventField}},
+				bson.M{"$indexOfArray": bson.A{fieldIDs, "$fieldId"}},
+				-1,
+			}}},
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": respCol.Name(),
+			"let":  bson.M{"sid": "$_id"},
+			"pipeline": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{
+					"formId":    form.ID,
+					"deletedAt": nil,
+					"$expr": bson.M{"$and": bson.A{
+						bson.M{"$ne": bson.A{"$$sid", ""}},
+						bson.M{"$eq": bson.A{"$sessionId", "$$sid"}},
+					}},
+				}}},
+				{{Key: "$sort", Value: bson.M{"submittedAt": 1}}},
+				{{Key: "$limit", Value: 1}},
+				{{Key: "$project", Value: bson.M{"_id": 0, "submittedAt": 1}}},
+			},
+			"as": "done",
+		}}},
+	}
+
+	// The per-session $group can outgrow the in-memory limit on busy forms.
+	cur, err := eventsCol.Aggregate(c.Context(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed funnel aggregation"})
+	}
+	defer cur.Close(c.Context())
+
+	fields := make([]funnelField, len(form.Fields))
+	for i, f := range form.Fields {
+		fields[i] = funnelField{ID: f.ID, Label: f.Label, Type: f.Type}
+	}
+	var views, viewers, starts, completedSessions, droppedBeforeFirst int64
+	var durations []float64
+
+	for cur.Next(c.Context()) {
+		var s struct {
+			ID       string     `bson:"_id"`
+			Views    int64      `bson:"views"`
+			Started  *time.Time `bson:"started"`
+			MaxField int        `bson:"maxField"`
+			Done     []struct {
+				SubmittedAt time.Time `bson:"submittedAt"`
+			} `bson:"done"`
+		}
+		if err := cur.Decode(&s); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed reading funnel aggregation"})
+		}
+		views += s.Views
+		if s.ID == "" {
+			continue // anonymous views
+		}
+		if s.Views > 0 {
+			viewers++
+		}
+		if s.Started == nil {
+			continue
+		}
+		starts++
+
+		reached := s.MaxField
+		completed := len(s.Done) > 0
+		if completed {
+			reached = len(fields) - 1
+			completedSessions++
+			if d := s.Done[0].SubmittedAt.Sub(*s.Started); d > 0 {
+				durations = append(durations, d.Seconds())
+			}
+		}
+		for i := 0; i <= reached && i < len(fields); i++ {
+			fields[i].Reached++
+		}
+		if !completed {
+			if reached < 0 {
+				droppedBeforeFirst++
+			} else if reached < len(fields) {
+				fields[reached].Dropped++
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed reading funnel aggregation"})
+	}
+
+	completions, err := respCol.CountDocuments(c.Context(), completionMatch)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed counting responses"})
+	}
+
+	var topDropOff interface{}
+	var worst *funnelField
+	for i := range fields {
+		fields[i].ReachedRate = rate(fields[i].Reached, starts)
+		if fields[i].Dropped > 0 && (worst == nil || fields[i].Dropped > worst.Dropped) {
+			worst = &fields[i]
+		}
+	}
+	if worst != nil {
+		topDropOff = fiber.Map{"fieldId": worst.ID, "label": worst.Label, "dropped": worst.Dropped}
+	}
+
+	var median interface{}
+	if len(durations) > 0 {
+		sort.Float64s(durations)
+		mid := len(durations) / 2
+		if len(durations)%2 == 1 {
+			median = durations[mid]
+		} else {
+			median = (durations[mid-1] + durations[mid]) / 2
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"views":                   views,
+		"uniqueViewers":           viewers,
+		"starts":                  starts,
+		"startRate":               rate(starts, viewers),
+		"fields":                  fields,
+		"droppedBeforeFirstField": droppedBeforeFirst,
+		"completedSessions":       completedSessions,
+		"completionRate":          rate(completedSessions, starts),
+		"completions":             completions,
+		"medianSecondsToComplete": median,
+		"topDropOff":              topDropOff,
+	})
+}
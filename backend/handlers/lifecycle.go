@@ -0,0 +1,179 @@
+// Form publish lifecycle: effective status, submission gating and the
+// background scheduler that opens and closes forms on time.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const defaultClosedMessage = "This form is no longer accepting responses."
+
+// liveStatuses are stored statuses whose form is publicly visible.
+var liveStatuses = bson.A{models.StatusScheduled, models.StatusOpen, models.StatusClosed, models.StatusPublished}
+
+// validStatus reports whether s may be set through the API.
+func validStatus(s string) bool {
+	switch s {
+	case models.StatusDraft, models.StatusScheduled, models.StatusOpen,
+		models.StatusClosed, models.StatusArchived, models.StatusPublished:
+		return true
+	}
+	return false
+}
+
+// effectiveStatus resolves the stored status against opensAt/closesAt, so a
+// form is reported correctly even before the scheduler has caught up.
+func effectiveStatus(status string, opensAt, closesAt *time.Time, now time.Time) string {
+	switch status {
+	case models.StatusScheduled, models.StatusOpen, models.StatusPublished:
+		if opensAt != nil && now.Before(*opensAt) {
+			return models.StatusScheduled
+		}
+		if closesAt != nil && !now.Before(*closesAt) {
+			return models.StatusClosed
+		}
+		return models.StatusOpen
+	}
+	return status
+}
+
+func closedMessage(msg string) string {
+	if msg == "" {
+		return defaultClosedMessage
+	}
+	return msg
+}
+
+var errResponseCapReached = errors.New("response limit reached")
+
+// reserveResponseSlot atomically claims the next response slot on the form's
+// counter, refusing with errResponseCapReached once max slots are taken. It
+// returns the slot number (1-based). Forms saved before the counter existed
+// are seeded from their live responses first.
+func reserveResponseSlot(ctx context.Context, formsCol, respCol *mongo.Collection, hdr formHeader) (int64, error) {
+	if hdr.ResponseCount == nil {
+		if err := seedResponseCount(ctx, formsCol, respCol, hdr.ID); err != nil {
+			return 0, err
+		}
+	}
+	filter := bson.M{"_id": hdr.ID, "deletedAt": nil}
+	if hdr.MaxResponses != nil {
+		filter["responseCount"] = bson.M{"$lt": *hdr.MaxResponses}
+	}
+	var slot struct {
+		ResponseCount int64 `bson:"responseCount"`
+	}
+	err := formsCol.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"responseCount": 1}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"responseCount": 1}),
+	).Decode(&slot)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, errResponseCapReached
+	}
+	return slot.ResponseCount, err
+}
+
+// seedResponseCount initialises a missing counter from the live responses.
+func seedResponseCount(ctx context.Context, formsCol, respCol *mongo.Collection, formID primitive.ObjectID) error {
+	n, err := respCol.CountDocuments(ctx, bson.M{"formId": formID, "deletedAt": nil})
+	if err != nil {
+		return err
+	}
+	_, err = formsCol.UpdateOne(ctx,
+		bson.M{"_id": formID, "responseCount": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"responseCount": n}},
+	)
+	return err
+}
+
+// adjustResponseCount moves the counter when a response is released, trashed
+// or restored.
+func adjustResponseCount(ctx context.Context, formsCol *mongo.Collection, formID primitive.ObjectID, delta int64) {
+	if _, err := formsCol.UpdateOne(ctx,
+		bson.M{"_id": formID, "responseCount": bson.M{"$exists": true}},
+		bson.M{"$inc": bson.M{"responseCount": delta}},
+	); err != nil {
+		log.Printf("lifecycle: adjust response count on form %s failed: %v", formID.Hex(), err)
+	}
+}
+
+// setFormStatus persists a status transition and emits a realtime status event.
+func setFormStatus(ctx context.Context, formsCol *mongo.Collection, formID primitive.ObjectID, status string) {
+	res, err := formsCol.UpdateOne(ctx,
+		bson.M{"_id": formID, "status": bson.M{"$ne": status}},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		log.Printf("lifecycle: set status %s on form %s failed: %v", status, formID.Hex(), err)
+		return
+	}
+	if res.ModifiedCount > 0 {
+		rtPublish(formID.Hex(), rtEvent{Type: rtEventStatus, Data: map[string]string{"status": status}})
+	}
+}
+
+// RunLifecycleScheduler periodically opens scheduled forms whose opensAt has
+// passed and closes open forms whose closesAt has passed. Blocks until ctx ends.
+func RunLifecycleScheduler(ctx context.Context, formsCol *mongo.Collection, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runLifecycleTick(ctx, formsCol, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runLifecycleTick(ctx context.Context, formsCol *mongo.Collection, now time.Time) {
+	transition := func(filter bson.M, status string) {
+		cur, err := formsCol.Find(ctx, filter)
+		if err != nil {
+			log.Printf("lifecycle: scan for %s failed: %v", status, err)
+			return
+		}
+		var ids []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cur.All(ctx, &ids); err != nil {
+			log.Printf("lifecycle: read for %s failed: %v", status, err)
+			return
+		}
+		for _, d := range ids {
+			setFormStatus(ctx, formsCol, d.ID, status)
+		}
+	}
+
+	// Close first so a form whose whole window has already passed ends closed.
+	transition(bson.M{
+		"status":    bson.M{"$in": bson.A{models.StatusScheduled, models.StatusOpen, models.StatusPublished}},
+		"closesAt":  bson.M{"$lte": now},
+		"deletedAt": nil,
+	}, models.StatusClosed)
+
+	transition(bson.M{
+		"status":    models.StatusScheduled,
+		"opensAt":   bson.M{"$lte": now},
+		"deletedAt": nil,
+		"$or": bson.A{
+			bson.M{"closesAt": nil},
+			bson.M{"closesAt": bson.M{"$gt": now}},
+		},
+	}, models.StatusOpen)
+}
@@ -0,0 +1,201 @@
+// Keyset (cursor) pagination shared by list endpoints, with the legacy
+// page/limit offset mode kept for existing clients.
+
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+)
+
+// pageCursor is the decoded form of an opaque cursor token: the sort it was
+// issued for and the sort key + _id of the last item returned.
+type pageCursor struct {
+	Field string             `bson:"f"`
+	Order int                `bson:"o"`
+	Value interface{}        `bson:"v"`
+	ID    primitive.ObjectID `bson:"i"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func encodeCursor(pc pageCursor) string {
+	b, err := bson.Marshal(pc)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(token string) (pageCursor, error) {
+	var pc pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pc, errInvalidCursor
+	}
+	if err := bson.Unmarshal(b, &pc); err != nil || pc.ID.IsZero() || !validCursorValue(pc.Field, pc.Value) {
+		return pc, errInvalidCursor
+	}
+	return pc, nil
+}
+
+// validCursorValue checks a decoded sort key has the type its field is
+// stored with. The value is spliced into the keyset filter, so anything
+// else (a document of query operators included) must be refused.
+func validCursorValue(field string, v interface{}) bool {
+	switch field {
+	case "submittedAt", "updatedAt", "createdAt":
+		switch v.(type) {
+		case primitive.DateTime, time.Time:
+			return true
+		}
+	case "title":
+		_, ok := v.(string)
+		return ok
+	}
+	return false
+}
+
+// pageSpec describes how one list request pages through results.
+type pageSpec struct {
+	field      string
+	order      int // 1 asc, -1 desc
+	limit      int
+	page       int // offset mode only
+	cursorMode bool
+	after      *pageCursor
+	withTotal  bool
+}
+
+// parsePageSpec reads sort/order/limit plus either cursor (keyset mode) or
+// page (offset mode). Keyset mode is selected by the presence of the cursor
+// param, empty for the first page; totals there are opt-in via count=true.
+func parsePageSpec(c *fiber.Ctx, sorts []string, defaultLimit, maxLimit int) (pageSpec, error) {
+	p := pageSpec{field: sorts[0], order: -1}
+
+	if s := c.Query("sort"); s != "" {
+		if !analytics.ContainsString(sorts, s) {
+			return p, errors.New("sort must be one of " + joinQuoted(sorts))
+		}
+		p.field = s
+	}
+	switch c.Query("order", "desc") {
+	case "desc":
+	case "asc":
+		p.order = 1
+	default:
+		return p, errors.New(`order must be "asc" or "desc"`)
+	}
+
+	p.limit, _ = strconv.Atoi(c.Query("limit", strconv.Itoa(defaultLimit)))
+	if p.limit < 1 || p.limit > maxLimit {
+		p.limit = defaultLimit
+	}
+
+	if c.Context().QueryArgs().Has("cursor") {
+		p.cursorMode = true
+		p.withTotal = c.QueryBool("count", false)
+		if token := c.Query("cursor"); token != "" {
+			pc, err := decodeCursor(token)
+			if err != nil {
+				return p, err
+			}
+			// A cursor only makes sense for the ordering it was issued under.
+			if pc.Field != p.field || pc.Order != p.order {
+				return p, errors.New("cursor does not match sort/order")
+			}
+			p.after = &pc
+		}
+		return p, nil
+	}
+
+	p.withTotal = true
+	p.page, _ = strconv.Atoi(c.Query("page", "1"))
+	if p.page < 1 {
+		p.page = 1
+	}
+	return p, nil
+}
+
+func joinQuoted(ss []string) string {
+	out := ""
+	for i, s := range ss {
+		if i > 0 {
+			out += ", "
+		}
+		out += strconv.Quote(s)
+	}
+	return out
+}
+
+// apply adds the keyset condition to filter and returns find options with a
+// stable (sort field, _id) ordering. One extra row is fetched to detect more.
+func (p pageSpec) apply(filter bson.M) (bson.M, *options.FindOptions) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: p.field, Value: p.order}, {Key: "_id", Value: p.order}}).
+		SetLimit(int64(p.limit) + 1)
+
+	if !p.cursorMode {
+		opts.SetSkip(int64((p.page - 1) * p.limit))
+		return filter, opts
+	}
+	if p.after == nil {
+		return filter, opts
+	}
+
+	cmp := "$lt"
+	if p.order == 1 {
+		cmp = "$gt"
+	}
+	keyset := bson.M{"$or": bson.A{
+		bson.M{p.field: bson.M{cmp: p.after.Value}},
+		bson.M{p.field: p.after.Value, "_id": bson.M{cmp: p.after.ID}},
+	}}
+
+	out := bson.M{}
+	for k, v := range filter {
+		out[k] = v
+	}
+	and, _ := out["$and"].(bson.A)
+	out["$and"] = append(append(bson.A{}, and...), keyset)
+	return out, opts
+}
+
+// result trims the look-ahead row and builds the response body. total is
+// only included when requested (always in offset mode, for compatibility).
+func (p pageSpec) result(items []bson.M, total int64) fiber.Map {
+	hasMore := len(items) > p.limit
+	if hasMore {
+		items = items[:p.limit]
+	}
+	if items == nil {
+		items = []bson.M{}
+	}
+
+	out := fiber.Map{
+		"items":   items,
+		"limit":   p.limit,
+		"hasMore": hasMore,
+	}
+	if hasMore {
+		last := items[len(items)-1]
+		id, _ := last["_id"].(primitive.ObjectID)
+		out["nextCursor"] = encodeCursor(pageCursor{Field: p.field, Order: p.order, Value: last[p.field], ID: id})
+	}
+	if !p.cursorMode {
+		out["page"] = p.page
+	}
+	if p.withTotal {
+		out["total"] = total
+	}
+	return out
+}
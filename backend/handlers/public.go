@@ -1,14 +1,18 @@
-// Handler for fetching a published form by slug for public access.
+// Handler for fetching a live (scheduled, open or closed) form by slug for public access.
 
 package handlers
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
 )
 
 // GET /public/forms/:slug
@@ -21,14 +25,48 @@ func GetFormBySlug(c *fiber.Ctx) error {
 
 	// Return a "public-safe" view of the form (no admin metadata)
 	var form struct {
-		Title  string      `bson:"title" json:"title"`
-		Fields interface{} `bson:"fields" json:"fields"`
-		Slug   string      `bson:"slug" json:"slug"`
-		Status string      `bson:"status" json:"status"`
+		ID            primitive.ObjectID `bson:"_id" json:"-"`
+		Title         string             `bson:"title" json:"title"`
+		Fields        interface{}        `bson:"fields" json:"fields"`
+		Slug          string             `bson:"slug" json:"slug"`
+		Status        string             `bson:"status" json:"status"`
+		OpensAt       *time.Time         `bson:"opensAt" json:"opensAt,omitempty"`
+		ClosesAt      *time.Time         `bson:"closesAt" json:"closesAt,omitempty"`
+		ClosedMessage string             `bson:"closedMessage" json:"closedMessage,omitempty"`
+		Accepting     bool               `bson:"-" json:"acceptingResponses"`
+	}
+
+	filter := bson.M{"slug": slug, "status": bson.M{"$in": liveStatuses}, "deletedAt": nil}
+	if err := col.FindOne(ctx, filter).Decode(&form); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "form not found or unpublished"})
+		}
+		// Old slugs permanently redirect to the form's current slug.
+		var moved struct {
+			Slug string `bson:"slug"`
+		}
+		redirect := bson.M{"previousSlugs": slug, "status": bson.M{"$in": liveStatuses}, "deletedAt": nil}
+		if err := col.FindOne(ctx, redirect).Decode(&moved); err != nil || moved.Slug == "" {
+			return c.Status(404).JSON(fiber.Map{"error": "form not found or unpublished"})
+		}
+		c.Set(fiber.HeaderLocation, "/public/forms/"+moved.Slug)
+		return c.Status(fiber.StatusMovedPermanently).JSON(fiber.Map{"slug": moved.Slug})
 	}
 
-	if err := col.FindOne(ctx, bson.M{"slug": slug, "status": "published"}).Decode(&form); err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "form not found or unpublished"})
+	// Views feed the respondent funnel; the session ties them to later events.
+	recordFormEvent(ctx, c.Locals("events").(*mongo.Collection), models.FormEvent{
+		FormID:    form.ID,
+		SessionID: requestSession(c),
+		Type:      models.EventView,
+		At:        time.Now(),
+	})
+
+	form.Status = effectiveStatus(form.Status, form.OpensAt, form.ClosesAt, time.Now())
+	form.Accepting = form.Status == models.StatusOpen
+	if form.Status == models.StatusClosed {
+		form.ClosedMessage = closedMessage(form.ClosedMessage)
+	} else {
+		form.ClosedMessage = ""
 	}
 
 	return c.JSON(form)
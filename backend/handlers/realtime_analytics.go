@@ -4,19 +4,82 @@ package handlers
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
 )
 
-// GET /forms
+const defaultSSERetryMs = 3000
+
+// sseRetry reads SSE_RETRY_MS, the reconnect delay sent to clients, falling
+// back to 3 seconds.
+func sseRetry() int {
+	ms, err := strconv.Atoi(os.Getenv("SSE_RETRY_MS"))
+	if err != nil || ms < 1 {
+		ms = defaultSSERetryMs
+	}
+	return ms
+}
+
+// lastEventID reads the Last-Event-ID header browsers send on reconnect, or
+// ?lastEventId= for clients that can't set headers. Zero means none.
+func lastEventID(c *fiber.Ctx) uint64 {
+	s := c.Get("Last-Event-ID")
+	if s == "" {
+		s = c.Query("lastEventId")
+	}
+	id, _ := strconv.ParseUint(s, 10, 64)
+	return id
+}
+
+// writeSSE writes and flushes one event; id 0 omits the id field so the
+// client's last event ID stays where it was. fasthttp doesn't report client
+// disconnects, so the write error is how a stream learns it should end.
+func writeSSE(w *bufio.Writer, id uint64, event string, data interface{}) error {
+	b, _ := json.Marshal(data)
+	if id > 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return w.Flush()
+}
+
+// writeSSEPing writes a heartbeat comment to keep proxies from timing out.
+func writeSSEPing(w *bufio.Writer) error {
+	fmt.Fprint(w, ": ping\n\n")
+	return w.Flush()
+}
+
+// rtEventData is the payload sent for a notification. Response events carry
+// no data here (the raw response is for the redacting live feed only), and
+// events without data still carry an object.
+func rtEventData(ev rtEvent) interface{} {
+	if ev.Data == nil || ev.Type == rtEventResponse {
+		return struct{}{}
+	}
+	return ev.Data
+}
+
+// GET /forms/:id/analytics/stream
+//
+// Every notification carries an id. A reconnect with Last-Event-ID replays
+// the missed notifications from the server's bounded buffer and follows
+// them with a current snapshot; when the buffer can't cover the gap, or on
+// a fresh connection, the stream starts with a full snapshot. Snapshots
+// come from the shared per-form broadcaster, so bursts of submissions
+// arrive as at most one snapshot per push interval.
 func StreamAnalytics(c *fiber.Ctx) error {
-	respCol := c.Locals("responses").(*mongo.Collection)
+	db := c.Locals("db").(*mongo.Database)
 
 	formOID, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
@@ -24,45 +87,64 @@ func StreamAnalytics(c *fiber.Ctx) error {
 	}
 	formID := formOID.Hex()
 
+	// Filters are fixed for the life of the stream.
+	match, status, err := analyticsMatch(c, formOID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	segment := querySegment(c, "")
+	after := lastEventID(c)
+
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
 
-		ch, unsubscribe := rtSubscribe(formID)
-		defer unsubscribe()
+		sub := rtSubscribe(formID, after)
+		defer sub.Unsubscribe()
 
 		// heartbeat to keep proxies alive
 		heartbeat := time.NewTicker(15 * time.Second)
 		defer heartbeat.Stop()
 
-		// helper to run the same aggregations
-		sendAnalytics := func() error {
-			payload, err := computeAnalytics(c, respCol, formOID)
-			if err != nil {
-				// send minimal error event (optional)
-				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
-				w.Flush()
-				return nil
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry())
+
+		// initial push: replayed notifications when resuming, else a snapshot
+		initial := !sub.Resumed
+		for _, ev := range sub.Replay {
+			if writeSSE(w, ev.ID, ev.Type, rtEventData(ev)) != nil {
+				return
 			}
-			b, _ := json.Marshal(payload)
-			fmt.Fprintf(w, "event: analytics\ndata: %s\n\n", b)
-			return nil
+			initial = initial || rtAffectsAnalytics(ev)
+		}
+		if w.Flush() != nil {
+			return
 		}
 
-		// initial push
-		_ = sendAnalytics()
+		updates, stop := subscribeAnalytics(db, formOID, segment, match, initial)
+		defer stop()
 
+		// A failed write means the client is gone; returning runs stop, so
+		// the shared broadcaster stops computing for it.
 		for {
+			var err error
 			select {
 			case <-c.Context().Done():
 				return
 			case <-heartbeat.C:
-
-				fmt.Fprint(w, ": ping\n\n")
-				w.Flush()
-			case <-ch:
-				_ = sendAnalytics()
+				err = writeSSEPing(w)
+			case ev := <-sub.C:
+				err = writeSSE(w, ev.ID, ev.Type, rtEventData(ev))
+			case upd := <-updates:
+				if upd.Err != nil {
+					// send minimal error event (optional)
+					err = writeSSE(w, 0, "error", upd.Err.Error())
+				} else {
+					err = writeSSE(w, 0, "analytics", upd.Result)
+				}
+			}
+			if err != nil {
+				return
 			}
 		}
 	})
@@ -70,78 +152,13 @@ func StreamAnalytics(c *fiber.Ctx) error {
 	return nil
 }
 
-// computeAnalytics mirrors handlers.FormAnalytics logic
-func computeAnalytics(c *fiber.Ctx, respCol *mongo.Collection, formID primitive.ObjectID) (map[string]interface{}, error) {
-	total, err := respCol.CountDocuments(c.Context(), bson.M{"formId": formID})
+// streamSnapshot reloads the form on every push so field edits made while
+// the stream is open are reflected. Unfiltered streams read the stored
+// counters, so a push costs one document read.
+func streamSnapshot(ctx context.Context, db *mongo.Database, formID primitive.ObjectID, match bson.M, filtered bool) (analytics.Result, error) {
+	form, _, err := loadForm(ctx, db.Collection("forms"), formID.Hex())
 	if err != nil {
-		return nil, err
-	}
-
-	// ratings agg
-	ratingPipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"formId": formID}}},
-		{{Key: "$project", Value: bson.M{"answers": 1}}},
-		{{Key: "$project", Value: bson.M{"kv": bson.M{"$objectToArray": "$answers"}}}},
-		{{Key: "$unwind", Value: "$kv"}},
-		{{Key: "$match", Value: bson.M{"kv.v": bson.M{"$type": "number"}}}},
-		{{Key: "$group", Value: bson.M{
-			"_id":   "$kv.k",
-			"avg":   bson.M{"$avg": "$kv.v"},
-			"min":   bson.M{"$min": "$kv.v"},
-			"max":   bson.M{"$max": "$kv.v"},
-			"count": bson.M{"$sum": 1},
-		}}},
+		return analytics.Result{}, err
 	}
-	cur, err := respCol.Aggregate(c.Context(), ratingPipeline)
-	if err != nil {
-		return nil, err
-	}
-	var ratings []bson.M
-	if err := cur.All(c.Context(), &ratings); err != nil {
-		return nil, err
-	}
-
-	// option counts
-	optionPipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"formId": formID}}},
-		{{Key: "$project", Value: bson.M{"kv": bson.M{"$objectToArray": "$answers"}}}},
-		{{Key: "$unwind", Value: "$kv"}},
-		{{Key: "$project", Value: bson.M{
-			"fieldId": "$kv.k",
-			"val":     "$kv.v",
-			"isArray": bson.M{"$eq": bson.A{bson.M{"$type": "$kv.v"}, "array"}},
-		}}},
-		{{Key: "$facet", Value: bson.M{
-			"arrays": mongo.Pipeline{
-				{{Key: "$match", Value: bson.M{"isArray": true}}},
-				{{Key: "$unwind", Value: "$val"}},
-				{{Key: "$project", Value: bson.M{"fieldId": 1, "option": "$val"}}},
-			},
-			"scalars": mongo.Pipeline{
-				{{Key: "$match", Value: bson.M{"isArray": false}}},
-				{{Key: "$project", Value: bson.M{"fieldId": 1, "option": "$val"}}},
-			},
-		}}},
-		{{Key: "$project", Value: bson.M{"all": bson.M{"$concatArrays": bson.A{"$arrays", "$scalars"}}}}},
-		{{Key: "$unwind", Value: "$all"}},
-		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$all"}}},
-		{{Key: "$group", Value: bson.M{
-			"_id":   bson.M{"fieldId": "$fieldId", "option": "$option"},
-			"count": bson.M{"$sum": 1},
-		}}},
-	}
-	cur2, err := respCol.Aggregate(c.Context(), optionPipeline)
-	if err != nil {
-		return nil, err
-	}
-	var optionCounts []bson.M
-	if err := cur2.All(c.Context(), &optionCounts); err != nil {
-		return nil, err
-	}
-
-	return map[string]interface{}{
-		"totalResponses": total,
-		"ratings":        ratings,
-		"optionCounts":   optionCounts,
-	}, nil
+	return analytics.Snapshot(ctx, db, form, match, filtered)
 }
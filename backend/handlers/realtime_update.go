@@ -1,50 +1,63 @@
-// In-memory pub/sub to notify subscribers of real-time form response updates.
+// Realtime pub/sub to notify subscribers of form updates, through a
+// pluggable notifier (in-memory by default).
 
 package handlers
 
 import (
-	"sync"
-)
+	"log"
 
-var (
-	rtMu sync.Mutex
-	// key = formId hex; value
-	rtSubs = map[string]map[chan struct{}]struct{}{}
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/realtime"
 )
 
-func rtSubscribe(formID string) (ch chan struct{}, unsubscribe func()) {
-	ch = make(chan struct{}, 1)
+// Realtime event types delivered to subscribers.
+const (
+	rtEventResponse = "response"
+	rtEventStatus   = "status"
+	rtEventForm     = "form" // definition or settings edited
+)
 
-	rtMu.Lock()
-	if _, ok := rtSubs[formID]; !ok {
-		rtSubs[formID] = make(map[chan struct{}]struct{})
+// rtAffectsAnalytics reports whether ev can change a form's analytics, so
+// cached snapshots must be recomputed.
+func rtAffectsAnalytics(ev rtEvent) bool {
+	switch ev.Type {
+	case rtEventResponse, rtEventStatus, rtEventForm:
+		return true
 	}
-	rtSubs[formID][ch] = struct{}{}
-	rtMu.Unlock()
-
-	unsubscribe = func() {
-		rtMu.Lock()
-		if set, ok := rtSubs[formID]; ok {
-			delete(set, ch)
-			if len(set) == 0 {
-				delete(rtSubs, formID)
-			}
-		}
-		rtMu.Unlock()
-		close(ch)
-	}
-	return ch, unsubscribe
+	return false
+}
+
+// rtEvent is a single notification for a form. Data is event specific and
+// must be JSON-serialisable.
+type rtEvent = realtime.Event
+
+var notifier realtime.Notifier = realtime.NewMemory()
+
+// UseNotifier replaces the realtime notifier; call before serving requests.
+func UseNotifier(n realtime.Notifier) {
+	notifier = n
 }
 
+// rtSubscribe follows formID's events; a non-zero after replays what was
+// published since that event ID.
+func rtSubscribe(formID string, after uint64) realtime.Subscription {
+	return notifier.Subscribe(formID, after)
+}
+
+// rtNotify signals that formID's responses changed (e.g. a delete or
+// restore) without a single response to show.
 func rtNotify(formID string) {
-	rtMu.Lock()
-	subs := rtSubs[formID]
-	// fan-out non-blocking
-	for ch := range subs {
-		select {
-		case ch <- struct{}{}:
-		default:
-		}
+	rtPublish(formID, rtEvent{Type: rtEventResponse})
+}
+
+// rtNotifyResponse signals a new submission and carries it for live feeds.
+func rtNotifyResponse(r models.Response) {
+	rtPublish(r.FormID.Hex(), rtEvent{Type: rtEventResponse, Data: r})
+}
+
+// rtPublish fans ev out without blocking; slow subscribers miss events.
+func rtPublish(formID string, ev rtEvent) {
+	if err := notifier.Publish(formID, ev); err != nil {
+		log.Printf("realtime: publish %s for form %s failed: %v", ev.Type, formID, err)
 	}
-	rtMu.Unlock()
 }
@@ -0,0 +1,429 @@
+// WebSocket endpoint multiplexing realtime analytics, live responses and form
+// events for several forms over one connection, for clients whose proxies
+// buffer SSE.
+//
+// Client messages (JSON):
+//
+//	{"type":"auth","apiKey":"..."}          first message, unless X-API-Key was sent on upgrade
+//	{"type":"subscribe","formId":"...","topics":["analytics","responses","events"],
+//	 "q":"...","from":"...","to":"...","version":"...","lastEventId":42}
+//	{"type":"unsubscribe","formId":"..."}
+//
+// Server messages carry type, formId, id (the realtime event ID) and data:
+// "analytics" (snapshot), "response" (redacted submission), "status",
+// "form" and "export" (form events), plus "ready", "subscribed",
+// "unsubscribed", "lagged" and "error".
+
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	wsAuthTimeout  = 5 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsMaxForms     = 20
+	// wsQueueLimit bounds queued messages per connection; beyond it the
+	// oldest are dropped and the client is told how many it lost.
+	wsQueueLimit = 256
+)
+
+// Subscription topics.
+const (
+	wsTopicAnalytics = "analytics"
+	wsTopicResponses = "responses"
+	wsTopicEvents    = "events"
+)
+
+type wsRequest struct {
+	Type        string   `json:"type"`
+	APIKey      string   `json:"apiKey"`
+	FormID      string   `json:"formId"`
+	Topics      []string `json:"topics"`
+	Q           string   `json:"q"`
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Version     string   `json:"version"`
+	LastEventID uint64   `json:"lastEventId"`
+}
+
+type wsMessage struct {
+	Type    string      `json:"type"`
+	FormID  string      `json:"formId,omitempty"`
+	ID      uint64      `json:"id,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Dropped int         `json:"dropped,omitempty"`
+}
+
+// wsSubscription is one form followed by a connection.
+type wsSubscription struct {
+	formID primitive.ObjectID
+	topics map[string]bool
+	feed   *responseFeed
+	stop   func()
+}
+
+// wsOutbox decouples event delivery from a possibly slow socket. Discrete
+// messages queue up to a bound, oldest dropped first; analytics keep only
+// the newest snapshot per subscription, so however many arrive while the
+// client is busy it receives one.
+type wsOutbox struct {
+	mu        sync.Mutex
+	queue     []wsOutboxEntry
+	dropped   map[string]int
+	analytics map[*wsSubscription]wsMessage // newest snapshot
+	wake      chan struct{}
+}
+
+// wsOutboxEntry is a queued message and the subscription that produced it,
+// nil for session-level messages. Entries whose subscription has ended are
+// not sent.
+type wsOutboxEntry struct {
+	msg wsMessage
+	sub *wsSubscription
+}
+
+func newWSOutbox() *wsOutbox {
+	return &wsOutbox{dropped: map[string]int{}, analytics: map[*wsSubscription]wsMessage{}, wake: make(chan struct{}, 1)}
+}
+
+func (o *wsOutbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *wsOutbox) push(sub *wsSubscription, m wsMessage) {
+	o.mu.Lock()
+	if len(o.queue) >= wsQueueLimit {
+		o.dropped[o.queue[0].msg.FormID]++
+		o.queue = o.queue[1:]
+	}
+	o.queue = append(o.queue, wsOutboxEntry{msg: m, sub: sub})
+	o.mu.Unlock()
+	o.signal()
+}
+
+// setAnalytics replaces sub's unsent snapshot.
+func (o *wsOutbox) setAnalytics(sub *wsSubscription, m wsMessage) {
+	o.mu.Lock()
+	o.analytics[sub] = m
+	o.mu.Unlock()
+	o.signal()
+}
+
+// take empties the outbox.
+func (o *wsOutbox) take() ([]wsOutboxEntry, map[string]int, map[*wsSubscription]wsMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	queue, dropped, snapshots := o.queue, o.dropped, o.analytics
+	o.queue = nil
+	o.dropped, o.analytics = map[string]int{}, map[*wsSubscription]wsMessage{}
+	return queue, dropped, snapshots
+}
+
+// wsSession is one client connection.
+type wsSession struct {
+	conn     *websocket.Conn
+	ctx      context.Context
+	db       *mongo.Database
+	formsCol *mongo.Collection
+	out      *wsOutbox
+
+	mu   sync.Mutex
+	subs map[string]*wsSubscription
+}
+
+// apiKeyMatches compares against API_KEY; with no key configured every
+// client is allowed, as for the admin routes.
+func apiKeyMatches(got string) bool {
+	key := os.Getenv("API_KEY")
+	return key == "" || subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1
+}
+
+// GET /realtime/ws
+func RealtimeSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(426).JSON(fiber.Map{"error": "websocket upgrade required"})
+	}
+	c.Locals("wsAuthed", apiKeyMatches(c.Get("X-API-Key")))
+	return realtimeSocket(c)
+}
+
+var realtimeSocket = websocket.New(func(conn *websocket.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &wsSession{
+		conn:     conn,
+		ctx:      ctx,
+		db:       conn.Locals("db").(*mongo.Database),
+		formsCol: conn.Locals("forms").(*mongo.Collection),
+		out:      newWSOutbox(),
+		subs:     map[string]*wsSubscription{},
+	}
+	defer s.unsubscribeAll()
+
+	if authed, _ := conn.Locals("wsAuthed").(bool); !authed {
+		if err := s.authenticate(); err != nil {
+			s.writeNow(wsMessage{Type: "error", Error: err.Error()})
+			return
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		s.writeLoop()
+	}()
+
+	s.out.push(nil, wsMessage{Type: "ready"})
+	s.readLoop()
+	cancel()
+	<-done
+})
+
+// authenticate waits for an auth message.
+func (s *wsSession) authenticate() error {
+	_ = s.conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+	var req wsRequest
+	if err := s.conn.ReadJSON(&req); err != nil {
+		return errors.New("auth message expected")
+	}
+	if req.Type != "auth" || !apiKeyMatches(req.APIKey) {
+		return errors.New("unauthorized")
+	}
+	return s.conn.SetReadDeadline(time.Time{})
+}
+
+// writeNow writes outside the write loop; only before it starts.
+func (s *wsSession) writeNow(m wsMessage) {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_ = s.conn.WriteJSON(m)
+}
+
+func (s *wsSession) readLoop() {
+	for {
+		var req wsRequest
+		if err := s.conn.ReadJSON(&req); err != nil {
+			return
+		}
+		switch req.Type {
+		case "subscribe":
+			if err := s.subscribe(req); err != nil {
+				s.out.push(nil, wsMessage{Type: "error", FormID: req.FormID, Error: err.Error()})
+			}
+		case "unsubscribe":
+			s.unsubscribe(req.FormID)
+			s.out.push(nil, wsMessage{Type: "unsubscribed", FormID: req.FormID})
+		case "auth":
+			// already authenticated
+		default:
+			s.out.push(nil, wsMessage{Type: "error", Error: "unknown message type: " + req.Type})
+		}
+	}
+}
+
+func (s *wsSession) subscribe(req wsRequest) error {
+	formID, err := primitive.ObjectIDFromHex(req.FormID)
+	if err != nil {
+		return errors.New("invalid formId")
+	}
+	topics := map[string]bool{}
+	for _, t := range req.Topics {
+		switch t {
+		case wsTopicAnalytics, wsTopicResponses, wsTopicEvents:
+			topics[t] = true
+		default:
+			return errors.New("unknown topic: " + t)
+		}
+	}
+	if len(topics) == 0 {
+		topics = map[string]bool{wsTopicAnalytics: true, wsTopicResponses: true, wsTopicEvents: true}
+	}
+
+	form, _, err := loadForm(s.ctx, s.formsCol, formID.Hex())
+	if err != nil {
+		return err
+	}
+	seg := analyticsSegment{Q: req.Q, From: req.From, To: req.To, Version: req.Version}
+	match, _, err := seg.match(s.ctx, s.formsCol, formID)
+	if err != nil {
+		return err
+	}
+	sub := &wsSubscription{formID: formID, topics: topics}
+	if topics[wsTopicResponses] {
+		if sub.feed, err = newResponseFeed(form, req.Q); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if _, ok := s.subs[req.FormID]; ok {
+		s.mu.Unlock()
+		return errors.New("already subscribed")
+	}
+	if len(s.subs) >= wsMaxForms {
+		s.mu.Unlock()
+		return errors.New("too many subscriptions")
+	}
+	rs := rtSubscribe(req.FormID, req.LastEventID)
+	sub.stop = rs.Unsubscribe
+	s.subs[req.FormID] = sub
+	s.mu.Unlock()
+
+	// Acknowledge before anything the subscription itself queues.
+	s.out.push(nil, wsMessage{Type: "subscribed", FormID: req.FormID})
+
+	if topics[wsTopicAnalytics] {
+		initial := !rs.Resumed
+		for _, ev := range rs.Replay {
+			initial = initial || rtAffectsAnalytics(ev)
+		}
+		updates, stopAnalytics := subscribeAnalytics(s.db, formID, seg, match, initial)
+		sub.stop = func() {
+			stopAnalytics()
+			rs.Unsubscribe()
+		}
+		go func() {
+			for upd := range updates {
+				m := wsMessage{Type: "analytics", FormID: req.FormID, ID: upd.ID, Data: upd.Result}
+				if upd.Err != nil {
+					m = wsMessage{Type: "error", FormID: req.FormID, Error: upd.Err.Error()}
+				}
+				s.out.setAnalytics(sub, m)
+			}
+		}()
+	}
+
+	go func() {
+		for _, ev := range rs.Replay {
+			s.dispatch(sub, ev)
+		}
+		for ev := range rs.C {
+			s.dispatch(sub, ev)
+		}
+	}()
+	return nil
+}
+
+// dispatch routes one realtime event into the outbox according to the
+// subscription's topics.
+func (s *wsSession) dispatch(sub *wsSubscription, ev rtEvent) {
+	if !s.current(sub) {
+		return
+	}
+	formID := sub.formID.Hex()
+	if ev.Type != rtEventResponse {
+		if sub.topics[wsTopicEvents] {
+			s.out.push(sub, wsMessage{Type: ev.Type, FormID: formID, ID: ev.ID, Data: ev.Data})
+		}
+		return
+	}
+	if sub.feed != nil {
+		if r, ok := eventResponse(ev); ok {
+			sub.feed.refresh(s.ctx, s.formsCol, r.FormVersion)
+			if sub.feed.match(r) {
+				s.out.push(sub, wsMessage{Type: "response", FormID: formID, ID: ev.ID, Data: sub.feed.redact(r)})
+			}
+		}
+	}
+}
+
+func (s *wsSession) unsubscribe(formID string) {
+	s.mu.Lock()
+	sub, ok := s.subs[formID]
+	delete(s.subs, formID)
+	s.mu.Unlock()
+	if ok {
+		sub.stop()
+	}
+}
+
+func (s *wsSession) unsubscribeAll() {
+	s.mu.Lock()
+	subs := s.subs
+	s.subs = map[string]*wsSubscription{}
+	s.mu.Unlock()
+	for _, sub := range subs {
+		sub.stop()
+	}
+}
+
+// current reports whether sub is still active; a message queued by a
+// subscription that was since dropped, or replaced by a resubscribe with
+// another segment, must not reach the client. Session-level messages have
+// no subscription and are always current.
+func (s *wsSession) current(sub *wsSubscription) bool {
+	if sub == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subs[sub.formID.Hex()] == sub
+}
+
+// writeLoop is the only writer once the session is ready. It sends what
+// accumulated while the previous batch was being written, so a slow client
+// gets fewer, newer snapshots rather than a growing backlog.
+func (s *wsSession) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	write := func(m wsMessage) bool {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return s.conn.WriteJSON(m) == nil
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ping.C:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			continue
+		case <-s.out.wake:
+		}
+
+		queue, dropped, snapshots := s.out.take()
+		for formID, n := range dropped {
+			if !write(wsMessage{Type: "lagged", FormID: formID, Dropped: n}) {
+				return
+			}
+		}
+		for _, e := range queue {
+			if !s.current(e.sub) {
+				continue
+			}
+			if !write(e.msg) {
+				return
+			}
+		}
+		for sub, m := range snapshots {
+			if !s.current(sub) {
+				continue
+			}
+			if !write(m) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Live feed of individual submissions over SSE, filtered by field conditions
+// and with personal data redacted.
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const redactedAnswer = "[redacted]"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s().-]{6,}\d`)
+)
+
+// feedResponse is a submission as shown on the live feed. Redacted lists
+// the fields whose answers were withheld.
+type feedResponse struct {
+	ID          primitive.ObjectID     `json:"id"`
+	FormID      primitive.ObjectID     `json:"formId"`
+	FormVersion int64                  `json:"formVersion,omitempty"`
+	SubmittedAt time.Time              `json:"submittedAt"`
+	Answers     map[string]interface{} `json:"answers"`
+	Redacted    []string               `json:"redacted,omitempty"`
+}
+
+// responseFeed holds the form definition a feed redacts and filters with.
+type responseFeed struct {
+	form  models.Form
+	q     string
+	match responseMatcher
+}
+
+func newResponseFeed(form models.Form, q string) (*responseFeed, error) {
+	feed := &responseFeed{form: form, q: q}
+	if err := feed.compile(form); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// compile builds the matcher for form. Filters on personal data are refused
+// since matching on them would reveal the values.
+func (f *responseFeed) compile(form models.Form) error {
+	if f.q == "" {
+		f.match = func(models.Response) bool { return true }
+		return nil
+	}
+	clauses, err := parseQueryClauses(f.q)
+	if err != nil {
+		return err
+	}
+	for _, cl := range clauses {
+		for _, fd := range form.Fields {
+			if fd.ID == cl.field && fd.PII {
+				return fmt.Errorf("field %s holds personal data and can't be filtered on", fd.ID)
+			}
+		}
+	}
+	match, err := compileResponseMatcher(form, f.q)
+	if err != nil {
+		return err
+	}
+	f.match = match
+	return nil
+}
+
+// refresh reloads the form when a response was submitted against a newer
+// version, so PII flags and field changes apply from then on. A filter the
+// new version no longer supports keeps its previous meaning.
+func (f *responseFeed) refresh(ctx context.Context, col *mongo.Collection, version int64) {
+	if version <= f.form.Version {
+		return
+	}
+	form, _, err := loadForm(ctx, col, f.form.ID.Hex())
+	if err != nil {
+		return
+	}
+	f.form = form
+	_ = f.compile(form)
+}
+
+// redact applies the feed's rules: answers to PII fields and to fields no
+// longer on the form are withheld, and emails and phone numbers inside text
+// answers are masked. The respondent session is never shown.
+func (f *responseFeed) redact(r models.Response) feedResponse {
+	out := feedResponse{
+		ID:          r.ID,
+		FormID:      r.FormID,
+		FormVersion: r.FormVersion,
+		SubmittedAt: r.SubmittedAt,
+		Answers:     make(map[string]interface{}, len(r.Answers)),
+	}
+	fields := make(map[string]models.Field, len(f.form.Fields))
+	for _, fd := range f.form.Fields {
+		fields[fd.ID] = fd
+	}
+	for id, v := range r.Answers {
+		fd, known := fields[id]
+		switch {
+		case !known || fd.PII:
+			out.Answers[id] = redactedAnswer
+			out.Redacted = append(out.Redacted, id)
+		case fd.Type == "text":
+			if s, ok := v.(string); ok {
+				s = emailPattern.ReplaceAllString(s, "[email]")
+				v = phonePattern.ReplaceAllString(s, "[phone]")
+			}
+			out.Answers[id] = v
+		default:
+			out.Answers[id] = v
+		}
+	}
+	sort.Strings(out.Redacted)
+	return out
+}
+
+// eventResponse extracts the submission carried by a response event, which
+// arrives as JSON when relayed from another instance.
+func eventResponse(ev rtEvent) (models.Response, bool) {
+	switch d := ev.Data.(type) {
+	case models.Response:
+		return d, true
+	case json.RawMessage:
+		var r models.Response
+		if err := json.Unmarshal(d, &r); err == nil && !r.ID.IsZero() {
+			return r, true
+		}
+	}
+	return models.Response{}, false
+}
+
+// GET /forms/:id/responses/stream?q=
+//
+// Emits a "response" event per new submission matching q (the
+// ListResponses query language). Event ids and Last-Event-ID replay work as
+// on the analytics stream.
+func StreamResponses(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+
+	form, status, err := loadForm(c.Context(), formsCol, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	feed, err := newResponseFeed(form, c.Query("q"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	after := lastEventID(c)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+
+		sub := rtSubscribe(form.ID.Hex(), after)
+		defer sub.Unsubscribe()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		send := func(ev rtEvent) error {
+			if ev.Type != rtEventResponse {
+				return nil
+			}
+			r, ok := eventResponse(ev)
+			if !ok {
+				return nil // deletes and restores carry no submission
+			}
+			feed.refresh(c.Context(), formsCol, r.FormVersion)
+			if !feed.match(r) {
+				return nil
+			}
+			return writeSSE(w, ev.ID, "response", feed.redact(r))
+		}
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry())
+		for _, ev := range sub.Replay {
+			if send(ev) != nil {
+				return
+			}
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		// Any failed write means the client is gone; returning releases the
+		// subscription.
+		for {
+			var err error
+			select {
+			case <-c.Context().Done():
+				return
+			case <-heartbeat.C:
+				err = writeSSEPing(w)
+			case ev := <-sub.C:
+				err = send(ev)
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
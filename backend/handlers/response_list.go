@@ -1,15 +1,12 @@
-// Handler for listing responses of a form with pagination and sorting.
+// Handler for listing responses of a form with filtering, search, pagination and sorting.
 
 package handlers
 
 import (
-	"strconv"
-
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // GET /forms
@@ -21,23 +18,41 @@ func ListResponses(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
 	}
 
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	if page < 1 {
-		page = 1
+	spec, err := parsePageSpec(c, []string{"submittedAt"}, 50, 200)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	filter := bson.M{"formId": formID, "deletedAt": nil}
+
+	// q: field-aware filter clauses, validated against the form definition.
+	if q := c.Query("q"); q != "" {
+		formsCol := c.Locals("forms").(*mongo.Collection)
+		form, status, err := loadForm(c.Context(), formsCol, formID.Hex())
+		if err != nil {
+			return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+		}
+		conds, err := compileResponseQuery(form, q)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if len(conds) > 0 {
+			and := make(bson.A, len(conds))
+			for i, cond := range conds {
+				and[i] = cond
+			}
+			filter["$and"] = and
+		}
 	}
-	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	if limit < 1 || limit > 200 {
-		limit = 50
+	// search: full-text over text answers.
+	if search := c.Query("search"); search != "" {
+		filter["$text"] = bson.M{"$search": search}
 	}
-	skip := int64((page - 1) * limit)
 
-	filter := bson.M{"formId": formID}
-	opts := options.Find().
-		SetSort(bson.D{{Key: "submittedAt", Value: -1}}).
-		SetSkip(skip).
-		SetLimit(int64(limit))
+	pageFilter, opts := spec.apply(filter)
+	opts.SetProjection(bson.M{"searchText": 0})
 
-	cur, err := col.Find(c.Context(), filter, opts)
+	cur, err := col.Find(c.Context(), pageFilter, opts)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to list responses"})
 	}
@@ -46,15 +61,12 @@ func ListResponses(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to read responses"})
 	}
 
-	total, err := col.CountDocuments(c.Context(), filter)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to count responses"})
+	var total int64
+	if spec.withTotal {
+		if total, err = col.CountDocuments(c.Context(), filter); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to count responses"})
+		}
 	}
 
-	return c.JSON(fiber.Map{
-		"items": items,
-		"page":  page,
-		"limit": limit,
-		"total": total,
-	})
+	return c.JSON(spec.result(items, total))
 }
@@ -0,0 +1,343 @@
+// Response query language: field-aware filters over answers and submittedAt,
+// compiled to MongoDB filters that can use the answers wildcard index.
+//
+// Clauses are separated by ";" and ANDed together:
+//
+//	rating>=4; role in [dev,qa]; comment~slow; submittedAt>=2024-01-01
+//
+// Operators: = != > >= < <= (comparison), ~ (text contains, case-insensitive),
+// in / nin (list membership, values in [a,b,...]). Values may be quoted.
+
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// maxQueryClauses keeps hostile queries from building huge filters.
+const maxQueryClauses = 20
+
+var clausePattern = regexp.MustCompile(`^\s*([\w.-]+)\s*(>=|<=|!=|=|>|<|~|\bnin\b|\bin\b)\s*(.*?)\s*$`)
+
+// queryClause is one parsed "field op value" condition.
+type queryClause struct {
+	field  string
+	op     string
+	values []string // one value, or the members of an in/nin list
+}
+
+// parseQueryClauses splits q into syntactically valid clauses.
+func parseQueryClauses(q string) ([]queryClause, error) {
+	var out []queryClause
+	for _, raw := range strings.Split(q, ";") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		m := clausePattern.FindStringSubmatch(raw)
+		if m == nil {
+			return nil, fmt.Errorf("invalid filter clause: %q", strings.TrimSpace(raw))
+		}
+		cl := queryClause{field: m[1], op: m[2]}
+		if cl.op == "in" || cl.op == "nin" {
+			list := strings.TrimSpace(m[3])
+			if !strings.HasPrefix(list, "[") || !strings.HasSuffix(list, "]") {
+				return nil, fmt.Errorf("%s expects a list like [a,b]: %q", cl.op, strings.TrimSpace(raw))
+			}
+			for _, v := range strings.Split(list[1:len(list)-1], ",") {
+				if v = unquote(strings.TrimSpace(v)); v != "" {
+					cl.values = append(cl.values, v)
+				}
+			}
+			if len(cl.values) == 0 {
+				return nil, fmt.Errorf("%s list is empty: %q", cl.op, strings.TrimSpace(raw))
+			}
+		} else {
+			cl.values = []string{unquote(m[3])}
+		}
+		out = append(out, cl)
+		if len(out) > maxQueryClauses {
+			return nil, fmt.Errorf("at most %d filter clauses allowed", maxQueryClauses)
+		}
+	}
+	return out, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// compileResponseQuery validates q against the form's field types and
+// returns the conditions to AND into a responses filter.
+func compileResponseQuery(form models.Form, q string) ([]bson.M, error) {
+	clauses, err := parseQueryClauses(q)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]models.Field, len(form.Fields))
+	for _, f := range form.Fields {
+		fields[f.ID] = f
+	}
+
+	conds := make([]bson.M, 0, len(clauses))
+	for _, cl := range clauses {
+		var cond bson.M
+		if cl.field == "submittedAt" {
+			cond, err = compileTimeClause(cl)
+		} else if f, ok := fields[cl.field]; ok {
+			cond, err = compileFieldClause(f, cl)
+		} else {
+			err = fmt.Errorf("unknown field: %s", cl.field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+var comparisonOps = map[string]string{
+	"=": "$eq", "!=": "$ne", ">": "$gt", ">=": "$gte", "<": "$lt", "<=": "$lte",
+	"in": "$in", "nin": "$nin",
+}
+
+func compileFieldClause(f models.Field, cl queryClause) (bson.M, error) {
+	key := "answers." + f.ID
+
+	switch f.Type {
+	case "rating":
+		if cl.op == "~" {
+			return nil, fmt.Errorf("field %s is a rating; use a numeric comparison", f.ID)
+		}
+		nums := make([]interface{}, len(cl.values))
+		for i, v := range cl.values {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %s expects a number, got %q", f.ID, v)
+			}
+			nums[i] = n
+		}
+		return bson.M{key: bson.M{comparisonOps[cl.op]: clauseOperand(cl, nums)}}, nil
+
+	case "mc", "checkbox":
+		switch cl.op {
+		case "=", "!=", "in", "nin":
+		default:
+			return nil, fmt.Errorf("field %s supports only =, !=, in, nin", f.ID)
+		}
+		vals := make([]interface{}, len(cl.values))
+		for i, v := range cl.values {
+			if !analytics.ContainsString(f.Options, v) {
+				return nil, fmt.Errorf("field %s has no option %q", f.ID, v)
+			}
+			vals[i] = v
+		}
+		// For checkbox arrays, = / in match responses that selected the option(s).
+		return bson.M{key: bson.M{comparisonOps[cl.op]: clauseOperand(cl, vals)}}, nil
+
+	case "text":
+		switch cl.op {
+		case "~":
+			return bson.M{key: bson.M{"$regex": regexp.QuoteMeta(cl.values[0]), "$options": "i"}}, nil
+		case "=", "!=", "in", "nin":
+			vals := make([]interface{}, len(cl.values))
+			for i, v := range cl.values {
+				vals[i] = v
+			}
+			return bson.M{key: bson.M{comparisonOps[cl.op]: clauseOperand(cl, vals)}}, nil
+		}
+		return nil, fmt.Errorf("field %s is text; use =, !=, ~, in or nin", f.ID)
+	}
+	return nil, fmt.Errorf("field %s has unsupported type %s", f.ID, f.Type)
+}
+
+// clauseOperand returns the list for in/nin and the single value otherwise.
+func clauseOperand(cl queryClause, vals []interface{}) interface{} {
+	if cl.op == "in" || cl.op == "nin" {
+		return vals
+	}
+	return vals[0]
+}
+
+// compileTimeClause handles submittedAt. Dates without a time cover the whole
+// UTC day, so "submittedAt=2024-05-01" matches every submission that day.
+func compileTimeClause(cl queryClause) (bson.M, error) {
+	if cl.op == "~" || cl.op == "in" || cl.op == "nin" {
+		return nil, fmt.Errorf("submittedAt supports only =, !=, >, >=, <, <=")
+	}
+	t, dayOnly, err := parseQueryTime(cl.values[0])
+	if err != nil {
+		return nil, err
+	}
+	if dayOnly {
+		end := t.AddDate(0, 0, 1)
+		switch cl.op {
+		case "=":
+			return bson.M{"submittedAt": bson.M{"$gte": t, "$lt": end}}, nil
+		case "!=":
+			return bson.M{"$or": bson.A{
+				bson.M{"submittedAt": bson.M{"$lt": t}},
+				bson.M{"submittedAt": bson.M{"$gte": end}},
+			}}, nil
+		case ">":
+			return bson.M{"submittedAt": bson.M{"$gte": end}}, nil
+		case "<=":
+			return bson.M{"submittedAt": bson.M{"$lt": end}}, nil
+		}
+	}
+	return bson.M{"submittedAt": bson.M{comparisonOps[cl.op]: t}}, nil
+}
+
+func parseQueryTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("submittedAt expects RFC3339 or YYYY-MM-DD, got %q", s)
+}
+
+// responseMatcher evaluates a compiled query against a single response in
+// memory, with the same semantics as the MongoDB filter: != and nin match
+// missing answers, array answers match when any element does.
+type responseMatcher func(r models.Response) bool
+
+// compileResponseMatcher validates q like compileResponseQuery and returns
+// an in-memory matcher for it.
+func compileResponseMatcher(form models.Form, q string) (responseMatcher, error) {
+	if _, err := compileResponseQuery(form, q); err != nil {
+		return nil, err
+	}
+	clauses, _ := parseQueryClauses(q)
+	fields := make(map[string]models.Field, len(form.Fields))
+	for _, f := range form.Fields {
+		fields[f.ID] = f
+	}
+	return func(r models.Response) bool {
+		for _, cl := range clauses {
+			if !matchClause(fields[cl.field], cl, r) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func matchClause(f models.Field, cl queryClause, r models.Response) bool {
+	if cl.field == "submittedAt" {
+		return matchTimeClause(cl, r.SubmittedAt)
+	}
+	answer := r.Answers[f.ID]
+
+	if f.Type == "rating" {
+		n, ok := analytics.ToFloat(answer)
+		if !ok {
+			return cl.op == "!=" || cl.op == "nin"
+		}
+		negated := cl.op == "!=" || cl.op == "nin"
+		for _, v := range cl.values {
+			want, _ := strconv.ParseFloat(v, 64)
+			if negated && n == want {
+				return false
+			}
+			if !negated && compareFloat(cl.op, n, want) {
+				return true
+			}
+		}
+		return negated
+	}
+
+	vals := analytics.AnswerStrings(answer)
+	switch cl.op {
+	case "~":
+		needle := strings.ToLower(cl.values[0])
+		for _, v := range vals {
+			if strings.Contains(strings.ToLower(v), needle) {
+				return true
+			}
+		}
+		return false
+	case "=", "in":
+		for _, v := range vals {
+			if analytics.ContainsString(cl.values, v) {
+				return true
+			}
+		}
+		return false
+	case "!=", "nin":
+		for _, v := range vals {
+			if analytics.ContainsString(cl.values, v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func compareFloat(op string, a, b float64) bool {
+	switch op {
+	case "=", "in":
+		return a == b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// matchTimeClause mirrors compileTimeClause, including whole-day dates.
+func matchTimeClause(cl queryClause, at time.Time) bool {
+	t, dayOnly, err := parseQueryTime(cl.values[0])
+	if err != nil {
+		return false
+	}
+	if dayOnly {
+		end := t.AddDate(0, 0, 1)
+		inDay := !at.Before(t) && at.Before(end)
+		switch cl.op {
+		case "=":
+			return inDay
+		case "!=":
+			return !inDay
+		case ">":
+			return !at.Before(end)
+		case "<=":
+			return at.Before(end)
+		}
+	}
+	switch cl.op {
+	case "=":
+		return at.Equal(t)
+	case "!=":
+		return !at.Equal(t)
+	case ">":
+		return at.After(t)
+	case ">=":
+		return !at.Before(t)
+	case "<":
+		return at.Before(t)
+	case "<=":
+		return !at.After(t)
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Backfill of the full-text search field for responses stored before it was
+// written on submission.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const searchBackfillBatch = 500
+
+// responseSearchText concatenates the text answers for the full-text index;
+// choice and rating answers are left out.
+func responseSearchText(fields []models.Field, answers map[string]interface{}) string {
+	var parts []string
+	for _, f := range fields {
+		if f.Type != "text" {
+			continue
+		}
+		if s, ok := answers[f.ID].(string); ok && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// BackfillSearchText writes searchText on the responses of the given forms
+// (all forms when ids is empty) that don't have it yet, from each form's
+// current text fields. Trashed forms and responses are included so they are
+// searchable once restored.
+func BackfillSearchText(ctx context.Context, db *mongo.Database, ids []string) error {
+	filter := bson.M{}
+	if len(ids) > 0 {
+		oids := make([]primitive.ObjectID, 0, len(ids))
+		for _, id := range ids {
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return errors.New("invalid form id: " + id)
+			}
+			oids = append(oids, oid)
+		}
+		filter["_id"] = bson.M{"$in": oids}
+	}
+
+	cur, err := db.Collection("forms").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"fields": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	respCol := db.Collection("responses")
+	for cur.Next(ctx) {
+		var form models.Form
+		if err := cur.Decode(&form); err != nil {
+			return err
+		}
+		n, err := backfillFormSearchText(ctx, respCol, form)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("search: backfilled form %s (%d responses)", form.ID.Hex(), n)
+		}
+	}
+	return cur.Err()
+}
+
+func backfillFormSearchText(ctx context.Context, respCol *mongo.Collection, form models.Form) (int, error) {
+	cur, err := respCol.Find(ctx,
+		bson.M{"formId": form.ID, "searchText": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"answers": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	done := 0
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := respCol.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+		done += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for cur.Next(ctx) {
+		var r models.Response
+		if err := cur.Decode(&r); err != nil {
+			return done, err
+		}
+		// An empty string is stored too, so the response isn't revisited.
+		batch = append(batch, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": r.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"searchText": responseSearchText(form.Fields, r.Answers)}}))
+		if len(batch) == searchBackfillBatch {
+			if err := flush(); err != nil {
+				return done, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return done, err
+	}
+	return done, flush()
+}
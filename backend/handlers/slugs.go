@@ -0,0 +1,248 @@
+// Slug validation, availability checks and slug changes with permanent redirects.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+const (
+	slugMinLen = 3
+	slugMaxLen = 64
+)
+
+// Lowercase alphanumerics separated by single hyphens.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// reservedSlugs would shadow app routes or read as official pages.
+var reservedSlugs = map[string]struct{}{
+	"admin": {}, "analytics": {}, "api": {}, "app": {}, "dashboard": {},
+	"edit": {}, "export": {}, "exports": {}, "forms": {}, "healthz": {},
+	"help": {}, "login": {}, "logout": {}, "new": {}, "public": {},
+	"readyz": {}, "settings": {}, "signup": {}, "static": {}, "support": {},
+	"templates": {}, "trash": {},
+}
+
+var errSlugTaken = errors.New("slug is already in use")
+
+// validateSlug checks charset, length and reserved words.
+func validateSlug(slug string) error {
+	if len(slug) < slugMinLen || len(slug) > slugMaxLen {
+		return fmt.Errorf("slug must be %d-%d characters", slugMinLen, slugMaxLen)
+	}
+	if !slugPattern.MatchString(slug) {
+		return errors.New("slug may contain only lowercase letters, digits and single hyphens")
+	}
+	if _, ok := reservedSlugs[slug]; ok {
+		return fmt.Errorf("slug %q is reserved", slug)
+	}
+	return nil
+}
+
+// slugOwner returns the id of the form that holds slug as its current or a
+// previous (redirecting) slug, or a zero id when it is free. Trashed forms
+// keep their slugs so they can be restored.
+func slugOwner(ctx context.Context, col *mongo.Collection, slug string) (primitive.ObjectID, error) {
+	var doc struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := col.FindOne(ctx,
+		bson.M{"$or": bson.A{bson.M{"slug": slug}, bson.M{"previousSlugs": slug}}},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return primitive.NilObjectID, nil
+	}
+	return doc.ID, err
+}
+
+// suggestSlugs proposes up to three free variants of slug.
+func suggestSlugs(ctx context.Context, col *mongo.Collection, slug string) []string {
+	base := slug
+	if len(base) > slugMaxLen-5 {
+		base = base[:slugMaxLen-5]
+	}
+	candidates := []string{
+		fmt.Sprintf("%s-2", base),
+		fmt.Sprintf("%s-3", base),
+		fmt.Sprintf("%s-%s", base, time.Now().Format("2006")),
+		fmt.Sprintf("%s-%s", base, randomHex(2)),
+		fmt.Sprintf("%s-%s", base, randomHex(2)),
+	}
+
+	out := make([]string, 0, 3)
+	for _, s := range candidates {
+		if validateSlug(s) != nil {
+			continue
+		}
+		if owner, err := slugOwner(ctx, col, s); err == nil && owner.IsZero() {
+			out = append(out, s)
+		}
+		if len(out) == 3 {
+			break
+		}
+	}
+	return out
+}
+
+// generatedSlugAttempts bounds how many random slugs are tried before giving up.
+const generatedSlugAttempts = 5
+
+var errNoFreeSlug = errors.New("could not generate a free slug")
+
+// freeSlug draws random slugs until one is held by no form.
+func freeSlug(ctx context.Context, col *mongo.Collection) (string, error) {
+	for i := 0; i < generatedSlugAttempts; i++ {
+		s := randomHex(4)
+		owner, err := slugOwner(ctx, col, s)
+		if err != nil {
+			return "", err
+		}
+		if owner.IsZero() {
+			return s, nil
+		}
+	}
+	return "", errNoFreeSlug
+}
+
+// insertWithGeneratedSlug inserts form under a fresh random slug, drawing
+// another one when a concurrent insert claims it first.
+func insertWithGeneratedSlug(ctx context.Context, col *mongo.Collection, form *models.Form) (*mongo.InsertOneResult, error) {
+	for i := 0; i < generatedSlugAttempts; i++ {
+		slug, err := freeSlug(ctx, col)
+		if err != nil {
+			return nil, err
+		}
+		form.Slug = slug
+		res, err := col.InsertOne(ctx, form)
+		if !mongo.IsDuplicateKeyError(err) {
+			return res, err
+		}
+	}
+	return nil, errNoFreeSlug
+}
+
+// slugConflict writes the 409 returned when a requested slug is taken.
+func slugConflict(c *fiber.Ctx, col *mongo.Collection, slug string) error {
+	return c.Status(409).JSON(fiber.Map{
+		"error":       errSlugTaken.Error(),
+		"slug":        slug,
+		"suggestions": suggestSlugs(c.Context(), col, slug),
+	})
+}
+
+// GET /slugs/:slug/availability
+func CheckSlugAvailability(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+	slug := c.Params("slug")
+
+	if err := validateSlug(slug); err != nil {
+		return c.JSON(fiber.Map{"slug": slug, "available": false, "reason": err.Error()})
+	}
+	owner, err := slugOwner(c.Context(), col, slug)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to check slug"})
+	}
+	if !owner.IsZero() {
+		return c.JSON(fiber.Map{
+			"slug":        slug,
+			"available":   false,
+			"reason":      errSlugTaken.Error(),
+			"suggestions": suggestSlugs(c.Context(), col, slug),
+		})
+	}
+	return c.JSON(fiber.Map{"slug": slug, "available": true})
+}
+
+// PUT /forms/:id/slug
+func ChangeSlug(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+
+	var body struct {
+		Slug string `json:"slug"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if err := validateSlug(body.Slug); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	form, status, err := loadForm(c.Context(), col, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if form.Slug == body.Slug {
+		return c.JSON(form)
+	}
+
+	owner, err := slugOwner(c.Context(), col, body.Slug)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to check slug"})
+	}
+	// Reclaiming one of the form's own previous slugs is allowed.
+	if !owner.IsZero() && owner != form.ID {
+		return slugConflict(c, col, body.Slug)
+	}
+
+	// The old slug becomes a permanent redirect to the new one.
+	previous := withoutSlug(append(append([]string{}, form.PreviousSlugs...), form.Slug), body.Slug)
+	set := bson.M{"slug": body.Slug, "updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if len(previous) > 0 {
+		set["previousSlugs"] = previous
+	} else {
+		// An empty array would collide in the unique previousSlugs index.
+		update["$unset"] = bson.M{"previousSlugs": ""}
+	}
+
+	// Guard on the slug we read so concurrent changes cannot lose a redirect.
+	var currentSlug interface{} = form.Slug
+	if form.Slug == "" {
+		currentSlug = nil
+	}
+
+	var out models.Form
+	err = col.FindOneAndUpdate(c.Context(),
+		bson.M{"_id": form.ID, "slug": currentSlug, "deletedAt": nil},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&out)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return slugConflict(c, col, body.Slug)
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(409).JSON(fiber.Map{"error": "form changed concurrently; retry"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to change slug"})
+	}
+
+	return c.JSON(out)
+}
+
+// withoutSlug dedupes slugs and drops empty entries and drop.
+func withoutSlug(slugs []string, drop string) []string {
+	out := make([]string, 0, len(slugs))
+	seen := make(map[string]struct{}, len(slugs))
+	for _, s := range slugs {
+		if _, dup := seen[s]; dup || s == drop || s == "" {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+// Small statistics helpers used by the analytics endpoints.
+
+package handlers
+
+import "math"
+
+// chiSquareResult is Pearson's chi-square test of independence over a
+// contingency table.
+type chiSquareResult struct {
+	Statistic float64 `json:"statistic"`
+	DF        int     `json:"df"`
+	PValue    float64 `json:"pValue"`
+	// LowExpected counts cells with an expected frequency below 5; when that is
+	// more than ~20% of cells the approximation is unreliable.
+	LowExpected int `json:"lowExpectedCells"`
+}
+
+// chiSquare runs the test on counts. Empty rows and columns are ignored so
+// options nobody picked don't inflate the degrees of freedom.
+func chiSquare(counts [][]int64) chiSquareResult {
+	var res chiSquareResult
+	if len(counts) == 0 {
+		return res
+	}
+	rowTot := make([]float64, len(counts))
+	colTot := make([]float64, len(counts[0]))
+	var n float64
+	for i, row := range counts {
+		for j, v := range row {
+			rowTot[i] += float64(v)
+			colTot[j] += float64(v)
+			n += float64(v)
+		}
+	}
+	if n == 0 {
+		return res
+	}
+
+	rows, cols := 0, 0
+	for _, t := range rowTot {
+		if t > 0 {
+			rows++
+		}
+	}
+	for _, t := range colTot {
+		if t > 0 {
+			cols++
+		}
+	}
+	for i, row := range counts {
+		if rowTot[i] == 0 {
+			continue
+		}
+		for j, v := range row {
+			if colTot[j] == 0 {
+				continue
+			}
+			exp := rowTot[i] * colTot[j] / n
+			d := float64(v) - exp
+			res.Statistic += d * d / exp
+			if exp < 5 {
+				res.LowExpected++
+			}
+		}
+	}
+
+	res.DF = (rows - 1) * (cols - 1)
+	if res.DF <= 0 {
+		res.DF = 0
+		res.PValue = 1
+		return res
+	}
+	res.PValue = chiSquareSurvival(res.Statistic, res.DF)
+	return res
+}
+
+// chiSquareSurvival is P(X >= x) for X ~ chi-square(df).
+func chiSquareSurvival(x float64, df int) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return gammaQ(float64(df)/2, x/2)
+}
+
+// gammaQ is the regularized upper incomplete gamma function Q(a, x), by
+// series expansion below a+1 and Lentz's continued fraction above.
+func gammaQ(a, x float64) float64 {
+	const (
+		maxIter = 500
+		eps     = 1e-14
+		tiny    = 1e-300
+	)
+	lg, _ := math.Lgamma(a)
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < maxIter; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*eps {
+				break
+			}
+		}
+		return 1 - sum*math.Exp(-x+a*math.Log(x)-lg)
+	}
+
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < maxIter; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return math.Exp(-x+a*math.Log(x)-lg) * h
+}
+
+// significanceTest is a two-sample test of a difference between segments.
+type significanceTest struct {
+	Statistic   float64  `json:"statistic"`
+	DF          *float64 `json:"df,omitempty"`
+	PValue      float64  `json:"pValue"`
+	Significant bool     `json:"significant"`
+}
+
+// welchTTest compares two means given each sample's size and standard
+// deviation, without assuming equal variances. It needs two or more answers
+// per side and some spread in at least one of them.
+func welchTTest(m1, sd1 float64, n1 int64, m2, sd2 float64, n2 int64, alpha float64) *significanceTest {
+	if n1 < 2 || n2 < 2 {
+		return nil
+	}
+	v1 := sd1 * sd1 / float64(n1)
+	v2 := sd2 * sd2 / float64(n2)
+	se2 := v1 + v2
+	if se2 == 0 {
+		return nil
+	}
+	t := (m2 - m1) / math.Sqrt(se2)
+	df := se2 * se2 / (v1*v1/float64(n1-1) + v2*v2/float64(n2-1))
+	p := studentTTwoSided(t, df)
+	return &significanceTest{Statistic: t, DF: &df, PValue: p, Significant: p < alpha}
+}
+
+// twoProportionZTest compares x1/n1 with x2/n2 using the pooled proportion.
+func twoProportionZTest(x1, n1, x2, n2 int64, alpha float64) *significanceTest {
+	if n1 == 0 || n2 == 0 {
+		return nil
+	}
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return nil
+	}
+	z := (float64(x2)/float64(n2) - float64(x1)/float64(n1)) / se
+	p := math.Erfc(math.Abs(z) / math.Sqrt2)
+	return &significanceTest{Statistic: z, PValue: p, Significant: p < alpha}
+}
+
+// studentTTwoSided is P(|T| >= |t|) for T ~ Student-t(df).
+func studentTTwoSided(t, df float64) float64 {
+	return betaI(df/2, 0.5, df/(df+t*t))
+}
+
+// betaI is the regularized incomplete beta function I_x(a, b), evaluated by
+// continued fraction on whichever side converges faster.
+func betaI(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF is the continued fraction for betaI, by the modified Lentz method.
+func betaCF(a, b, x float64) float64 {
+	const (
+		maxIter = 500
+		eps     = 1e-14
+		tiny    = 1e-300
+	)
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		for _, an := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + an*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + an/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < eps {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+)
+
+func near(got, want, tol float64) bool { return math.Abs(got-want) <= tol }
+
+func TestBetaI(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{2, 3, 0.4, 0.5248}, // binomial tail: P(Bin(4, 0.4) >= 2)
+		{1, 1, 0.3, 0.3},    // uniform
+		{0.5, 0.5, 0.5, 0.5},
+		{5, 2, 0.9, 0.885735},
+		{2, 3, 0, 0},
+		{2, 3, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := betaI(tt.a, tt.b, tt.x); !near(got, tt.want, 1e-6) {
+			t.Errorf("betaI(%v, %v, %v) = %v, want %v", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestStudentTTwoSided(t *testing.T) {
+	tests := []struct {
+		t, df float64
+		want  float64
+	}{
+		{2.0, 10, 0.073388},
+		{-2.0, 10, 0.073388},
+		{2.228, 10, 0.05},
+		{1, 1, 0.5},                // Cauchy
+		{2, 2, 1 - 2/math.Sqrt(6)}, // closed form for df = 2
+		{0, 7, 1},
+	}
+	for _, tt := range tests {
+		if got := studentTTwoSided(tt.t, tt.df); !near(got, tt.want, 1e-4) {
+			t.Errorf("studentTTwoSided(%v, %v) = %v, want %v", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	res := welchTTest(10, 2, 10, 12, 3, 12, 0.05)
+	if res == nil {
+		t.Fatal("welchTTest returned nil")
+	}
+	if !near(res.Statistic, 1.865010, 1e-5) || !near(*res.DF, 19.190546, 1e-5) {
+		t.Errorf("t = %v, df = %v; want 1.865010, 19.190546", res.Statistic, *res.DF)
+	}
+	if !near(res.PValue, 0.077546, 1e-4) || res.Significant {
+		t.Errorf("p = %v, significant = %v; want 0.0775, false", res.PValue, res.Significant)
+	}
+	if res := welchTTest(10, 2, 50, 12, 3, 50, 0.05); res == nil || !res.Significant {
+		t.Errorf("larger samples should be significant: %+v", res)
+	}
+
+	nilCases := []struct {
+		name     string
+		sd1, sd2 float64
+		n1, n2   int64
+	}{
+		{"one answer in a", 2, 3, 1, 12},
+		{"one answer in b", 2, 3, 10, 1},
+		{"no spread", 0, 0, 10, 12},
+	}
+	for _, tc := range nilCases {
+		if res := welchTTest(10, tc.sd1, tc.n1, 12, tc.sd2, tc.n2, 0.05); res != nil {
+			t.Errorf("%s: got %+v, want nil", tc.name, res)
+		}
+	}
+}
+
+func TestTwoProportionZTest(t *testing.T) {
+	res := twoProportionZTest(50, 100, 65, 100, 0.05)
+	if res == nil {
+		t.Fatal("twoProportionZTest returned nil")
+	}
+	if !near(res.Statistic, 2.145596, 1e-5) || !near(res.PValue, 0.031905, 1e-5) || !res.Significant {
+		t.Errorf("got %+v, want z 2.1456, p 0.0319, significant", res)
+	}
+	if res.DF != nil {
+		t.Errorf("z-test reported df %v", *res.DF)
+	}
+
+	nilCases := []struct {
+		name           string
+		x1, n1, x2, n2 int64
+	}{
+		{"empty a", 0, 0, 3, 10},
+		{"empty b", 3, 10, 0, 0},
+		{"all selected", 10, 10, 20, 20},
+		{"none selected", 0, 10, 0, 20},
+	}
+	for _, tc := range nilCases {
+		if res := twoProportionZTest(tc.x1, tc.n1, tc.x2, tc.n2, 0.05); res != nil {
+			t.Errorf("%s: got %+v, want nil", tc.name, res)
+		}
+	}
+}
+
+func TestChiSquareSurvival(t *testing.T) {
+	tests := []struct {
+		x    float64
+		df   int
+		want float64
+	}{
+		{3.841459, 1, 0.05},
+		{5.991465, 2, 0.05},
+		{4, 2, math.Exp(-2)}, // closed form for df = 2
+		{0.5, 3, 0.918891},   // series branch
+		{10, 3, 0.018566},    // continued-fraction branch
+		{0, 4, 1},
+	}
+	for _, tt := range tests {
+		if got := chiSquareSurvival(tt.x, tt.df); !near(got, tt.want, 1e-5) {
+			t.Errorf("chiSquareSurvival(%v, %d) = %v, want %v", tt.x, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestChiSquare(t *testing.T) {
+	res := chiSquare([][]int64{{10, 20}, {30, 40}})
+	if res.DF != 1 || !near(res.Statistic, 0.793651, 1e-6) || !near(res.PValue, 0.372998, 1e-5) {
+		t.Errorf("2x2 table: %+v, want df 1, statistic 0.7937, p 0.3730", res)
+	}
+	if res.LowExpected != 0 {
+		t.Errorf("2x2 table: %d low expected cells, want 0", res.LowExpected)
+	}
+
+	// An unpicked option adds an empty column that must not raise df.
+	if res := chiSquare([][]int64{{10, 20, 0}, {30, 40, 0}}); res.DF != 1 || !near(res.PValue, 0.372998, 1e-5) {
+		t.Errorf("empty column: %+v", res)
+	}
+	if res := chiSquare([][]int64{{1, 2}, {3, 1}}); res.LowExpected != 4 {
+		t.Errorf("small table: %d low expected cells, want 4", res.LowExpected)
+	}
+
+	degenerate := []struct {
+		name   string
+		counts [][]int64
+		p      float64 // empty tables report no test at all
+	}{
+		{"no rows", nil, 0},
+		{"all zero", [][]int64{{0, 0}, {0, 0}}, 0},
+		{"one row", [][]int64{{5, 7, 9}}, 1},
+		{"one used column", [][]int64{{5, 0}, {7, 0}}, 1},
+	}
+	for _, tc := range degenerate {
+		res := chiSquare(tc.counts)
+		if res.DF != 0 || res.Statistic != 0 {
+			t.Errorf("%s: %+v, want df 0 and statistic 0", tc.name, res)
+		}
+		if res.PValue != tc.p {
+			t.Errorf("%s: p = %v, want %v", tc.name, res.PValue, tc.p)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Handlers for duplicating forms and creating forms from the template library.
+
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// cloneOptions controls what cloneForm carries over from the source form.
+type cloneOptions struct {
+	title         string
+	copySettings  bool
+	regenerateIDs bool
+}
+
+// cloneForm returns a fresh draft built from src. Responses are never part of
+// a form document, so they are never copied.
+func cloneForm(src models.Form, opt cloneOptions) models.Form {
+	now := time.Now()
+	out := models.Form{
+		Title:     src.Title,
+		Status:    models.StatusDraft,
+		Fields:    make([]models.Field, len(src.Fields)),
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if opt.title != "" {
+		out.Title = opt.title
+	}
+	copy(out.Fields, src.Fields)
+	if opt.regenerateIDs {
+		for i := range out.Fields {
+			out.Fields[i].ID = "f_" + randomHex(4)
+		}
+	}
+	if opt.copySettings {
+		out.Description = src.Description
+		out.MaxResponses = src.MaxResponses
+		out.ClosedMessage = src.ClosedMessage
+	}
+	return out
+}
+
+// randomHex returns 2n hex characters from crypto/rand.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// loadForm fetches a live (not trashed) form by hex id. On failure it returns
+// the HTTP status and a client-facing error.
+func loadForm(ctx context.Context, col *mongo.Collection, id string) (models.Form, int, error) {
+	var form models.Form
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return form, 400, errors.New("invalid id")
+	}
+	if err := col.FindOne(ctx, bson.M{"_id": oid, "deletedAt": nil}).Decode(&form); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return form, 404, errors.New("form not found")
+		}
+		return form, 500, errors.New("failed to fetch form")
+	}
+	return form, 200, nil
+}
+
+// insertClone saves a cloned form under a generated slug and writes the 201
+// response.
+func insertClone(c *fiber.Ctx, col *mongo.Collection, form models.Form) error {
+	res, err := insertWithGeneratedSlug(c.Context(), col, &form)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to save form"})
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		form.ID = oid
+	}
+	return c.Status(201).JSON(form)
+}
+
+// POST /forms/:id/duplicate
+func DuplicateForm(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+
+	var body struct {
+		Title        string `json:"title"`
+		CopySettings bool   `json:"copySettings"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+		}
+	}
+
+	src, status, err := loadForm(c.Context(), col, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	title := body.Title
+	if title == "" {
+		title = src.Title + " (copy)"
+	}
+	return insertClone(c, col, cloneForm(src, cloneOptions{
+		title:        title,
+		copySettings: body.CopySettings,
+	}))
+}
+
+// GET /templates
+func ListTemplates(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "title", Value: 1}}).
+		SetProjection(bson.M{"title": 1, "description": 1, "fields": 1, "updatedAt": 1})
+
+	cur, err := col.Find(c.Context(), bson.M{"isTemplate": true, "deletedAt": nil}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to list templates"})
+	}
+	var items []bson.M
+	if err := cur.All(c.Context(), &items); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read templates"})
+	}
+
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// POST /templates/:id/forms
+func CreateFormFromTemplate(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+
+	var body struct {
+		Title        string `json:"title"`
+		CopySettings bool   `json:"copySettings"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+		}
+	}
+
+	tpl, status, err := loadForm(c.Context(), col, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !tpl.IsTemplate {
+		return c.Status(404).JSON(fiber.Map{"error": "template not found"})
+	}
+
+	return insertClone(c, col, cloneForm(tpl, cloneOptions{
+		title:         body.Title,
+		copySettings:  body.CopySettings,
+		regenerateIDs: true,
+	}))
+}
@@ -0,0 +1,273 @@
+// Trash for soft-deleted forms and responses: listing, restore and the
+// purge job that permanently removes items after the retention window.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+)
+
+const defaultTrashRetentionDays = 30
+
+// trashRetention reads TRASH_RETENTION_DAYS, falling back to 30 days.
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// withTransaction runs fn inside a transaction when the deployment supports
+// it. Standalone servers (local dev) reject transactions; there fn runs
+// directly and the purge job's reconcile step repairs a partial cascade.
+func withTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	sess, err := client.StartSession()
+	if err != nil {
+		return fn(ctx)
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 { // IllegalOperation: no replica set
+		return fn(ctx)
+	}
+	return err
+}
+
+// GET /trash/forms
+func ListTrashedForms(c *fiber.Ctx) error {
+	col := c.Locals("forms").(*mongo.Collection)
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deletedAt", Value: -1}}).
+		SetLimit(200)
+	cur, err := col.Find(c.Context(), bson.M{"deletedAt": bson.M{"$ne": nil}}, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to list trash"})
+	}
+	var items []bson.M
+	if err := cur.All(c.Context(), &items); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read trash"})
+	}
+	withPurgeAt(items)
+
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// GET /trash/responses?formId=
+func ListTrashedResponses(c *fiber.Ctx) error {
+	col := c.Locals("responses").(*mongo.Collection)
+
+	filter := bson.M{"deletedAt": bson.M{"$ne": nil}}
+	if fid := c.Query("formId"); fid != "" {
+		oid, err := primitive.ObjectIDFromHex(fid)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid formId"})
+		}
+		filter["formId"] = oid
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deletedAt", Value: -1}}).
+		SetLimit(200)
+	cur, err := col.Find(c.Context(), filter, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to list trash"})
+	}
+	var items []bson.M
+	if err := cur.All(c.Context(), &items); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read trash"})
+	}
+	withPurgeAt(items)
+
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// withPurgeAt annotates trashed documents with when they will be purged.
+func withPurgeAt(items []bson.M) {
+	retention := trashRetention()
+	for _, it := range items {
+		if d, ok := it["deletedAt"].(primitive.DateTime); ok {
+			it["purgeAt"] = d.Time().Add(retention)
+		}
+	}
+}
+
+// POST /trash/forms/:id/restore
+func RestoreForm(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	responsesCol := c.Locals("responses").(*mongo.Collection)
+
+	oid, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	var trashed struct {
+		DeletedAt time.Time `bson:"deletedAt"`
+	}
+	if err := formsCol.FindOne(c.Context(), bson.M{"_id": oid, "deletedAt": bson.M{"$ne": nil}}).Decode(&trashed); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "form not in trash"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load form"})
+	}
+
+	restore := func(ctx context.Context) error {
+		// Only responses removed by the form's cascade come back.
+		if _, err := responsesCol.UpdateMany(ctx,
+			bson.M{"formId": oid, "deletedAt": trashed.DeletedAt},
+			bson.M{"$unset": bson.M{"deletedAt": ""}},
+		); err != nil {
+			return err
+		}
+		_, err := formsCol.UpdateOne(ctx,
+			bson.M{"_id": oid},
+			bson.M{"$unset": bson.M{"deletedAt": ""}, "$set": bson.M{"updatedAt": time.Now()}},
+		)
+		return err
+	}
+	if err := withTransaction(c.Context(), formsCol.Database().Client(), restore); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to restore form"})
+	}
+	analytics.MarkStale(c.Context(), c.Locals("analytics").(*mongo.Collection), oid)
+
+	return GetForm(c)
+}
+
+// POST /trash/responses/:id/restore
+func RestoreResponse(c *fiber.Ctx) error {
+	formsCol := c.Locals("forms").(*mongo.Collection)
+	responsesCol := c.Locals("responses").(*mongo.Collection)
+
+	oid, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	var resp struct {
+		FormID primitive.ObjectID `bson:"formId"`
+	}
+	if err := responsesCol.FindOne(c.Context(), bson.M{"_id": oid, "deletedAt": bson.M{"$ne": nil}}).Decode(&resp); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "response not in trash"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load response"})
+	}
+
+	// A response cannot outlive its form; restore the form first.
+	hdr, err := findFormHeader(c.Context(), formsCol, resp.FormID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(409).JSON(fiber.Map{"error": "form is in trash; restore the form first"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load form"})
+	}
+
+	// A restored response counts toward maxResponses like a new submission.
+	if _, err := reserveResponseSlot(c.Context(), formsCol, responsesCol, hdr); err != nil {
+		if errors.Is(err, errResponseCapReached) {
+			return c.Status(409).JSON(fiber.Map{"error": "form has reached its response limit"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "failed to restore response"})
+	}
+
+	res, err := responsesCol.UpdateOne(c.Context(),
+		bson.M{"_id": oid, "deletedAt": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deletedAt": ""}},
+	)
+	if err != nil || res.ModifiedCount == 0 {
+		adjustResponseCount(c.Context(), formsCol, resp.FormID, -1)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to restore response"})
+	}
+	analytics.MarkStale(c.Context(), c.Locals("analytics").(*mongo.Collection), resp.FormID)
+	rtNotify(resp.FormID.Hex())
+
+	return c.SendStatus(204)
+}
+
+// RunTrashPurger reconciles partially cascaded deletes and permanently
+// removes trash older than the retention window. Blocks until ctx ends.
+func RunTrashPurger(ctx context.Context, formsCol, responsesCol *mongo.Collection, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeTrash(ctx, formsCol, responsesCol, time.Now().Add(-trashRetention()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeTrash(ctx context.Context, formsCol, responsesCol *mongo.Collection, cutoff time.Time) {
+	cur, err := formsCol.Find(ctx,
+		bson.M{"deletedAt": bson.M{"$ne": nil}},
+		options.Find().SetProjection(bson.M{"deletedAt": 1}),
+	)
+	if err != nil {
+		log.Printf("trash: scan failed: %v", err)
+		return
+	}
+	var trashed []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		DeletedAt time.Time          `bson:"deletedAt"`
+	}
+	if err := cur.All(ctx, &trashed); err != nil {
+		log.Printf("trash: read failed: %v", err)
+		return
+	}
+
+	for _, f := range trashed {
+		if f.DeletedAt.After(cutoff) {
+			// Reconcile: finish a cascade that was interrupted without a transaction.
+			if _, err := responsesCol.UpdateMany(ctx,
+				bson.M{"formId": f.ID, "deletedAt": nil},
+				bson.M{"$set": bson.M{"deletedAt": f.DeletedAt}},
+			); err != nil {
+				log.Printf("trash: reconcile form %s failed: %v", f.ID.Hex(), err)
+			}
+			continue
+		}
+		// Responses go first so a failure never leaves orphans behind a purged form.
+		if _, err := responsesCol.DeleteMany(ctx, bson.M{"formId": f.ID}); err != nil {
+			log.Printf("trash: purge responses of form %s failed: %v", f.ID.Hex(), err)
+			continue
+		}
+		if _, err := formsCol.DeleteOne(ctx, bson.M{"_id": f.ID}); err != nil {
+			log.Printf("trash: purge form %s failed: %v", f.ID.Hex(), err)
+			continue
+		}
+		if _, err := formsCol.Database().Collection(analytics.Collection).DeleteOne(ctx, bson.M{"_id": f.ID}); err != nil {
+			log.Printf("trash: purge analytics of form %s failed: %v", f.ID.Hex(), err)
+		}
+		if _, err := formsCol.Database().Collection("events").DeleteMany(ctx, bson.M{"formId": f.ID}); err != nil {
+			log.Printf("trash: purge events of form %s failed: %v", f.ID.Hex(), err)
+		}
+	}
+
+	if _, err := responsesCol.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$ne": nil, "$lte": cutoff}}); err != nil {
+		log.Printf("trash: purge responses failed: %v", err)
+	}
+}
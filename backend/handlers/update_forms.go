@@ -1,8 +1,10 @@
-// Handler for updating form details, fields, and status with validation.
+// Handler for updating form details, fields, status and lifecycle settings with validation.
 
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
 	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
 )
 
@@ -25,9 +28,14 @@ func UpdateForm(c *fiber.Ctx) error {
 	}
 
 	var body struct {
-		Title  *string         `json:"title"`
-		Fields *[]models.Field `json:"fields"`
-		Status *string         `json:"status"`
+		Title         *string         `json:"title"`
+		Fields        *[]models.Field `json:"fields"`
+		Status        *string         `json:"status"`
+		OpensAt       *time.Time      `json:"opensAt"`
+		ClosesAt      *time.Time      `json:"closesAt"`
+		MaxResponses  *int64          `json:"maxResponses"`
+		ClosedMessage *string         `json:"closedMessage"`
+		IsTemplate    *bool           `json:"isTemplate"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
@@ -47,45 +55,89 @@ func UpdateForm(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "fields cannot be empty"})
 		}
 
-		//field IDs
-		seen := make(map[string]struct{}, len(*body.Fields))
-		for _, f := range *body.Fields {
-			if _, dup := seen[f.ID]; dup {
-				return c.Status(400).JSON(fiber.Map{"error": "duplicate field id: " + f.ID})
-			}
-			seen[f.ID] = struct{}{}
+		if err := validateFieldDefs(*body.Fields); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
+		set["fields"] = *body.Fields
+	}
+	// Lifecycle settings are cleared by sending null.
+	var raw map[string]json.RawMessage
+	_ = json.Unmarshal(c.Body(), &raw)
+	unset := bson.M{}
+	for _, key := range []string{"opensAt", "closesAt", "maxResponses"} {
+		if v, ok := raw[key]; ok && string(bytes.TrimSpace(v)) == "null" {
+			unset[key] = ""
+		}
+	}
 
-		// validation
-		for _, f := range *body.Fields {
-			if f.ID == "" || f.Type == "" || f.Label == "" {
-				return c.Status(400).JSON(fiber.Map{"error": "each field requires id, type, label"})
-			}
-			if (f.Type == "mc" || f.Type == "checkbox") && len(f.Options) == 0 {
-				return c.Status(400).JSON(fiber.Map{"error": "mc/checkbox require options"})
-			}
-			if f.Type == "rating" && (f.Min == nil || f.Max == nil || *f.Min >= *f.Max) {
-				return c.Status(400).JSON(fiber.Map{"error": "rating needs valid min/max"})
-			}
+	// Schedule rules apply to the form as it will be stored, so merge the
+	// request over the current values before checking them.
+	hdr, err := findFormHeader(c.Context(), col, oid)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(404).JSON(fiber.Map{"error": "form not found"})
 		}
-		set["fields"] = *body.Fields
+		return c.Status(500).JSON(fiber.Map{"error": "failed to load form"})
 	}
+	status, opensAt, closesAt := hdr.Status, hdr.OpensAt, hdr.ClosesAt
 	if body.Status != nil {
-		if *body.Status != "draft" && *body.Status != "published" {
+		if !validStatus(*body.Status) {
 			return c.Status(400).JSON(fiber.Map{"error": "invalid status"})
 		}
-		set["status"] = *body.Status
+		status = *body.Status
+		set["status"] = status
+	}
+	if body.OpensAt != nil {
+		opensAt = body.OpensAt
+		set["opensAt"] = *body.OpensAt
+	} else if _, ok := unset["opensAt"]; ok {
+		opensAt = nil
+	}
+	if body.ClosesAt != nil {
+		closesAt = body.ClosesAt
+		set["closesAt"] = *body.ClosesAt
+	} else if _, ok := unset["closesAt"]; ok {
+		closesAt = nil
+	}
+	if status == models.StatusScheduled && opensAt == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "scheduled status requires opensAt"})
+	}
+	if opensAt != nil && closesAt != nil && !closesAt.After(*opensAt) {
+		return c.Status(400).JSON(fiber.Map{"error": "closesAt must be after opensAt"})
+	}
+	if body.MaxResponses != nil {
+		if *body.MaxResponses < 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "maxResponses must be at least 1"})
+		}
+		set["maxResponses"] = *body.MaxResponses
+	}
+	if body.ClosedMessage != nil {
+		set["closedMessage"] = *body.ClosedMessage
+	}
+	if body.IsTemplate != nil {
+		set["isTemplate"] = *body.IsTemplate
 	}
 
-	if len(set) == 1 {
+	if len(set) == 1 && len(unset) == 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "no updatable fields provided"})
 	}
 
 	update["$set"] = set
-
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	update["$inc"] = bson.M{"version": 1}
+
+	// The version guard keeps a concurrent edit from invalidating the checks
+	// above between the read and the write. Forms created before versioning
+	// have no version field at all.
+	filter := bson.M{"_id": oid, "deletedAt": nil, "version": hdr.Version}
+	if hdr.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
 	res := col.FindOneAndUpdate(
 		c.Context(),
-		bson.M{"_id": oid},
+		filter,
 		update,
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	)
@@ -93,10 +145,21 @@ func UpdateForm(c *fiber.Ctx) error {
 	var out models.Form
 	if err := res.Decode(&out); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return c.Status(404).JSON(fiber.Map{"error": "form not found"})
+			return c.Status(409).JSON(fiber.Map{"error": "form was modified concurrently, retry"})
 		}
 		return c.Status(500).JSON(fiber.Map{"error": "failed to update form"})
 	}
+	invalidatePlan(out.ID)
+	if body.Fields != nil {
+		// Counters are kept per field type; a redefinition needs a rebuild.
+		analytics.MarkStale(c.Context(), c.Locals("analytics").(*mongo.Collection), out.ID)
+	}
+	rtPublish(out.ID.Hex(), rtEvent{Type: rtEventForm, Data: map[string]int64{"version": out.Version}})
+	if body.Status != nil || body.OpensAt != nil || body.ClosesAt != nil || len(unset) > 0 {
+		rtPublish(out.ID.Hex(), rtEvent{Type: rtEventStatus, Data: map[string]string{
+			"status": effectiveStatus(out.Status, out.OpensAt, out.ClosesAt, time.Now()),
+		}})
+	}
 
 	return c.JSON(out)
 }
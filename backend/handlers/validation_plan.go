@@ -0,0 +1,286 @@
+// Precompiled, per-form validation plans used by the submission hot path.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/models"
+)
+
+// fieldRule is a single field definition with its rules resolved up front
+// (compiled pattern, option lookup) so submissions never recompile them.
+type fieldRule struct {
+	field   models.Field
+	pattern *regexp.Regexp
+	options map[string]struct{}
+}
+
+// validationPlan is the compiled form of a form's field definitions.
+// Plans are immutable once built and safe to share between goroutines.
+type validationPlan struct {
+	formID  primitive.ObjectID
+	version int64
+	rules   []fieldRule
+}
+
+var (
+	planMu    sync.RWMutex
+	planCache = map[primitive.ObjectID]*validationPlan{}
+)
+
+// validateFieldDefs checks field definitions when a form is saved, including
+// that every pattern compiles, so bad regexes are rejected with a 400 up front.
+func validateFieldDefs(fields []models.Field) error {
+	seen := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		if _, dup := seen[f.ID]; dup {
+			return fmt.Errorf("duplicate field id: %s", f.ID)
+		}
+		seen[f.ID] = struct{}{}
+	}
+
+	for _, f := range fields {
+		if f.ID == "" || f.Type == "" || f.Label == "" {
+			return errors.New("each field requires id, type, label")
+		}
+		if (f.Type == "mc" || f.Type == "checkbox") && len(f.Options) == 0 {
+			return errors.New("mc/checkbox require options")
+		}
+		if f.Type == "rating" && (f.Min == nil || f.Max == nil || *f.Min >= *f.Max) {
+			return errors.New("rating needs valid min/max")
+		}
+		if f.Type == "rating" && int64(*f.Max)-int64(*f.Min) > analytics.MaxRatingSpan {
+			return fmt.Errorf("field %s rating scale may span at most %d", f.ID, analytics.MaxRatingSpan)
+		}
+		if f.MinLength != nil && f.MaxLength != nil && *f.MinLength > *f.MaxLength {
+			return fmt.Errorf("field %s minLength exceeds maxLength", f.ID)
+		}
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return fmt.Errorf("field %s has invalid pattern: %v", f.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compileValidationPlan builds a plan from a stored form. Field definitions
+// are validated on save, so a compile failure here means legacy data.
+func compileValidationPlan(form models.Form) (*validationPlan, error) {
+	plan := &validationPlan{
+		formID:  form.ID,
+		version: form.Version,
+		rules:   make([]fieldRule, 0, len(form.Fields)),
+	}
+	for _, f := range form.Fields {
+		rule := fieldRule{field: f}
+		if f.Pattern != "" {
+			re, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("field %s has invalid pattern", f.ID)
+			}
+			rule.pattern = re
+		}
+		if len(f.Options) > 0 {
+			rule.options = make(map[string]struct{}, len(f.Options))
+			for _, o := range f.Options {
+				rule.options[o] = struct{}{}
+			}
+		}
+		plan.rules = append(plan.rules, rule)
+	}
+	return plan, nil
+}
+
+// cachedPlan returns the plan for formID if one is cached at exactly version.
+func cachedPlan(formID primitive.ObjectID, version int64) (*validationPlan, bool) {
+	planMu.RLock()
+	defer planMu.RUnlock()
+	p, ok := planCache[formID]
+	if !ok || p.version != version {
+		return nil, false
+	}
+	return p, true
+}
+
+func storePlan(p *validationPlan) {
+	planMu.Lock()
+	if cur, ok := planCache[p.formID]; !ok || cur.version <= p.version {
+		planCache[p.formID] = p
+	}
+	planMu.Unlock()
+}
+
+// invalidatePlan drops the cached plan; called whenever a form's fields change.
+func invalidatePlan(formID primitive.ObjectID) {
+	planMu.Lock()
+	delete(planCache, formID)
+	planMu.Unlock()
+}
+
+// formHeader is the lightweight projection read on every submission. The
+// full field list is only fetched when the cached plan is missing or stale.
+type formHeader struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Status        string             `bson:"status"`
+	Version       int64              `bson:"version"`
+	OpensAt       *time.Time         `bson:"opensAt"`
+	ClosesAt      *time.Time         `bson:"closesAt"`
+	MaxResponses  *int64             `bson:"maxResponses"`
+	ClosedMessage string             `bson:"closedMessage"`
+	ResponseCount *int64             `bson:"responseCount"`
+}
+
+// loadValidationPlan resolves the plan for a form, reading only the header
+// when the cache already holds the current version.
+func loadValidationPlan(ctx context.Context, col *mongo.Collection, hdr formHeader) (*validationPlan, error) {
+	if p, ok := cachedPlan(hdr.ID, hdr.Version); ok {
+		return p, nil
+	}
+
+	var form models.Form
+	if err := col.FindOne(ctx, bson.M{"_id": hdr.ID}).Decode(&form); err != nil {
+		return nil, err
+	}
+	p, err := compileValidationPlan(form)
+	if err != nil {
+		return nil, err
+	}
+	storePlan(p)
+	return p, nil
+}
+
+// findFormHeader fetches the status/lifecycle/version projection for a form.
+func findFormHeader(ctx context.Context, col *mongo.Collection, formID primitive.ObjectID) (formHeader, error) {
+	var hdr formHeader
+	opts := options.FindOne().SetProjection(bson.M{
+		"status": 1, "version": 1,
+		"opensAt": 1, "closesAt": 1, "maxResponses": 1, "closedMessage": 1, "responseCount": 1,
+	})
+	err := col.FindOne(ctx, bson.M{"_id": formID, "deletedAt": nil}, opts).Decode(&hdr)
+	return hdr, err
+}
+
+// validate checks answers against the plan's precompiled rules.
+func (p *validationPlan) validate(answers map[string]interface{}) error {
+	for _, r := range p.rules {
+		f := r.field
+		val, present := answers[f.ID]
+
+		if f.Required && !present {
+			return fmt.Errorf("missing required field: %s", f.ID)
+		}
+		if !present {
+			continue
+		}
+
+		if f.Required {
+			switch f.Type {
+			case "text", "mc":
+				s, ok := val.(string)
+				if !ok || strings.TrimSpace(s) == "" {
+					return fmt.Errorf("field %s is required", f.ID)
+				}
+			case "checkbox":
+				arr, ok := val.([]interface{})
+				if !ok || len(arr) == 0 {
+					return fmt.Errorf("field %s is required", f.ID)
+				}
+			case "rating", "number":
+				if _, ok := val.(float64); !ok {
+					return fmt.Errorf("field %s is required", f.ID)
+				}
+			default:
+				if val == nil {
+					return fmt.Errorf("field %s is required", f.ID)
+				}
+			}
+		}
+
+		switch f.Type {
+		case "text":
+			s, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("field %s must be text", f.ID)
+			}
+			trimmed := strings.TrimSpace(s)
+
+			if f.MinLength != nil && len(trimmed) < *f.MinLength {
+				return fmt.Errorf("field %s must be at least %d characters", f.ID, *f.MinLength)
+			}
+			if f.MaxLength != nil && len(trimmed) > *f.MaxLength {
+				return fmt.Errorf("field %s must be at most %d characters", f.ID, *f.MaxLength)
+			}
+			if r.pattern != nil && !r.pattern.MatchString(trimmed) {
+				return fmt.Errorf("field %s does not match required format", f.ID)
+			}
+
+		case "rating":
+			num, ok := val.(float64)
+			if !ok {
+				return fmt.Errorf("field %s must be number", f.ID)
+			}
+			if f.Min != nil && f.Max != nil {
+				if int(num) < *f.Min || int(num) > *f.Max {
+					return fmt.Errorf("field %s rating must be between %d and %d", f.ID, *f.Min, *f.Max)
+				}
+			}
+		case "mc":
+			choice, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("field %s must be string (single choice)", f.ID)
+			}
+			if _, ok := r.options[choice]; !ok {
+				return fmt.Errorf("field %s must be one of %v", f.ID, f.Options)
+			}
+		case "checkbox":
+			arr, ok := val.([]interface{})
+			if !ok {
+				return fmt.Errorf("field %s must be an array of strings", f.ID)
+			}
+			if len(r.options) == 0 {
+				return fmt.Errorf("field %s has no options configured", f.ID)
+			}
+			for _, v := range arr {
+				s, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("field %s contains invalid option %v", f.ID, v)
+				}
+				if _, ok := r.options[s]; !ok {
+					return fmt.Errorf("field %s contains invalid option %v", f.ID, v)
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported field type: %s", f.Type)
+		}
+	}
+
+	return nil
+}
+
+// searchText builds the response's full-text search field.
+func (p *validationPlan) searchText(answers map[string]interface{}) string {
+	return responseSearchText(p.fields(), answers)
+}
+
+// fields returns the field definitions the plan was compiled from.
+func (p *validationPlan) fields() []models.Field {
+	out := make([]models.Field, len(p.rules))
+	for i, r := range p.rules {
+		out[i] = r.field
+	}
+	return out
+}
@@ -12,9 +12,11 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/joho/godotenv"
 
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/analytics"
 	"github.com/kulkarni1973onkar/dune-security-assignment/backend/config"
 	"github.com/kulkarni1973onkar/dune-security-assignment/backend/handlers"
 	"github.com/kulkarni1973onkar/dune-security-assignment/backend/middleware"
+	"github.com/kulkarni1973onkar/dune-security-assignment/backend/realtime"
 )
 
 func main() {
@@ -25,6 +27,37 @@ func main() {
 
 	config.EnsureIndexes(db)
 
+	// `server rebuild-analytics [formId...]` backfills analytics counters and
+	// the response search text, then exits.
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-analytics" {
+		if err := analytics.RebuildAll(context.Background(), db, os.Args[2:]); err != nil {
+			log.Fatalf("rebuild-analytics: %v", err)
+		}
+		if err := handlers.BackfillSearchText(context.Background(), db, os.Args[2:]); err != nil {
+			log.Fatalf("rebuild-analytics: search text: %v", err)
+		}
+		return
+	}
+
+	// Background jobs stop when the server exits.
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+	go handlers.RunLifecycleScheduler(bgCtx, db.Collection("forms"), time.Minute)
+	go handlers.RunTrashPurger(bgCtx, db.Collection("forms"), db.Collection("responses"), time.Hour)
+	go handlers.RunExportWorker(bgCtx, db, 5*time.Second)
+
+	// Realtime fan-out across instances needs change streams; single-node
+	// and dev setups (or REALTIME_NOTIFIER=memory) stay in-process.
+	if os.Getenv("REALTIME_NOTIFIER") != "memory" {
+		n, err := realtime.NewChangeStream(bgCtx, db.Collection(realtime.Collection))
+		if err != nil {
+			log.Printf("realtime: change streams unavailable, using in-memory notifier: %v", err)
+		} else {
+			go n.Run(bgCtx)
+			handlers.UseNotifier(n)
+		}
+	}
+
 	app := fiber.New()
 	app.Use(cors.New())
 
@@ -33,6 +66,9 @@ func main() {
 		c.Locals("db", db)
 		c.Locals("forms", db.Collection("forms"))
 		c.Locals("responses", db.Collection("responses"))
+		c.Locals("exports", db.Collection("exports"))
+		c.Locals("analytics", db.Collection(analytics.Collection))
+		c.Locals("events", db.Collection("events"))
 		return c.Next()
 	})
 
@@ -52,10 +88,18 @@ func main() {
 
 	// Public routes
 	app.Get("/public/forms/:slug", handlers.GetFormBySlug)
+	app.Post("/public/forms/:slug/events", handlers.RecordFormEvent)
 	app.Post("/forms/:id/responses", handlers.SubmitResponse)
 	app.Get("/forms/:id/analytics", handlers.FormAnalytics)
 	app.Get("/forms/:id/analytics/stream", handlers.StreamAnalytics)
+	app.Get("/realtime/ws", handlers.RealtimeSocket)
+	app.Get("/forms/:id/analytics/timeseries", handlers.FormAnalyticsTimeSeries)
+	app.Get("/forms/:id/analytics/crosstab", handlers.FormAnalyticsCrosstab)
+	app.Get("/forms/:id/analytics/text", handlers.FormTextAnalytics)
+	app.Get("/forms/:id/analytics/funnel", handlers.FormFunnel)
+	app.Get("/forms/:id/analytics/compare", handlers.FormAnalyticsCompare)
 	app.Get("/forms/:id/responses", handlers.ListResponses)
+	app.Get("/exports/:jobId/download", handlers.DownloadExport)
 
 	// Admin routes
 	admin := app.Group("/", middleware.APIKey())
@@ -64,6 +108,26 @@ func main() {
 	admin.Get("/forms/:id", handlers.GetForm)
 	admin.Patch("/forms/:id", handlers.UpdateForm)
 	admin.Delete("/forms/:id", handlers.DeleteForm)
+	admin.Delete("/forms/:id/responses/:responseId", handlers.DeleteResponse)
+	admin.Get("/forms/:id/responses/stream", handlers.StreamResponses)
+	admin.Get("/forms/:id/responses/export", handlers.ExportResponses)
+	admin.Post("/forms/:id/exports", handlers.CreateExportJob)
+	admin.Get("/exports/:jobId", handlers.GetExportJob)
+	admin.Post("/forms/:id/analytics/rebuild", handlers.RebuildFormAnalytics)
+	admin.Get("/realtime/metrics", handlers.AnalyticsPushMetrics)
+	admin.Post("/forms/:id/duplicate", handlers.DuplicateForm)
+	admin.Put("/forms/:id/slug", handlers.ChangeSlug)
+	admin.Get("/slugs/:slug/availability", handlers.CheckSlugAvailability)
+
+	// Templates
+	admin.Get("/templates", handlers.ListTemplates)
+	admin.Post("/templates/:id/forms", handlers.CreateFormFromTemplate)
+
+	// Trash
+	admin.Get("/trash/forms", handlers.ListTrashedForms)
+	admin.Get("/trash/responses", handlers.ListTrashedResponses)
+	admin.Post("/trash/forms/:id/restore", handlers.RestoreForm)
+	admin.Post("/trash/responses/:id/restore", handlers.RestoreResponse)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -0,0 +1,37 @@
+// Data model for asynchronous response export jobs and their artifacts.
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Export job statuses.
+const (
+	ExportQueued  = "queued"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+type ExportJob struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	FormID         primitive.ObjectID `json:"formId" bson:"formId"`
+	Format         string             `json:"format" bson:"format"`
+	CheckboxMode   string             `json:"checkbox" bson:"checkbox"`
+	MultiDelimiter string             `json:"multiDelimiter" bson:"multiDelimiter"`
+	From           *time.Time         `json:"from,omitempty" bson:"from,omitempty"`
+	To             *time.Time         `json:"to,omitempty" bson:"to,omitempty"`
+	Q              string             `json:"q,omitempty" bson:"q,omitempty"`
+	Status         string             `json:"status" bson:"status"`
+	Error          string             `json:"error,omitempty" bson:"error,omitempty"`
+	FilePath       string             `json:"-" bson:"filePath,omitempty"`
+	Size           int64              `json:"size,omitempty" bson:"size,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	StartedAt      *time.Time         `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	FinishedAt     *time.Time         `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+	ExpiresAt      *time.Time         `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
@@ -8,6 +8,17 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Form lifecycle statuses. "published" predates the scheduled/open/closed
+// lifecycle and is treated as an alias of "open".
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusOpen      = "open"
+	StatusClosed    = "closed"
+	StatusArchived  = "archived"
+	StatusPublished = "published"
+)
+
 type Field struct {
 	ID        string   `json:"id" bson:"id"`
 	Type      string   `json:"type" bson:"type"`
@@ -19,15 +30,30 @@ type Field struct {
 	MinLength *int     `json:"minLength,omitempty" bson:"minLength,omitempty"`
 	MaxLength *int     `json:"maxLength,omitempty" bson:"maxLength,omitempty"`
 	Pattern   string   `json:"pattern,omitempty"   bson:"pattern,omitempty"`
+	// PII marks answers as personal data; live feeds redact them.
+	PII bool `json:"pii,omitempty" bson:"pii,omitempty"`
 }
 
 type Form struct {
-	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Title       string             `json:"title" bson:"title"`
-	Description string             `json:"description,omitempty" bson:"description,omitempty"`
-	Status      string             `json:"status" bson:"status"`
-	Slug        string             `json:"slug,omitempty" bson:"slug,omitempty"`
-	Fields      []Field            `json:"fields" bson:"fields"`
-	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Title         string             `json:"title" bson:"title"`
+	Description   string             `json:"description,omitempty" bson:"description,omitempty"`
+	Status        string             `json:"status" bson:"status"`
+	Slug          string             `json:"slug,omitempty" bson:"slug,omitempty"`
+	PreviousSlugs []string           `json:"previousSlugs,omitempty" bson:"previousSlugs,omitempty"`
+	Fields        []Field            `json:"fields" bson:"fields"`
+	Version       int64              `json:"version" bson:"version"`
+	IsTemplate    bool               `json:"isTemplate,omitempty" bson:"isTemplate,omitempty"`
+
+	OpensAt       *time.Time `json:"opensAt,omitempty" bson:"opensAt,omitempty"`
+	ClosesAt      *time.Time `json:"closesAt,omitempty" bson:"closesAt,omitempty"`
+	MaxResponses  *int64     `json:"maxResponses,omitempty" bson:"maxResponses,omitempty"`
+	ClosedMessage string     `json:"closedMessage,omitempty" bson:"closedMessage,omitempty"`
+	// ResponseCount is the number of accepted submissions, kept by
+	// SubmitResponse so the cap can be enforced atomically.
+	ResponseCount int64 `json:"responseCount" bson:"responseCount"`
+
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
@@ -0,0 +1,46 @@
+// Data model for the per-form analytics counters maintained on submission.
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FormAnalytics holds running totals for a form's live responses, keyed by
+// field ID. Map keys are stored escaped (see analytics.CounterKey) since field
+// IDs and option labels may contain "." or "$".
+type FormAnalytics struct {
+	FormID primitive.ObjectID       `json:"formId" bson:"_id"`
+	Total  int64                    `json:"total" bson:"total"`
+	Fields map[string]FieldCounters `json:"fields" bson:"fields"`
+	// Seq is bumped by every write so rebuilds can detect concurrent changes.
+	Seq int64 `json:"seq" bson:"seq"`
+	// Complete is set by a rebuild; documents created by submission upserts
+	// alone only cover part of the form's responses.
+	Complete bool `json:"complete" bson:"complete"`
+	// Schema is the counters layout version a rebuild wrote; older documents
+	// are rebuilt on read.
+	Schema int `json:"schema" bson:"schema"`
+	// Pending counts submissions between analytics.Begin and Record; a
+	// rebuild can't tell whether its aggregation saw them, so it won't
+	// store counters while any are recent. PendingAt is the latest Begin.
+	Pending   int64      `json:"pending,omitempty" bson:"pending,omitempty"`
+	PendingAt *time.Time `json:"pendingAt,omitempty" bson:"pendingAt,omitempty"`
+	Stale     bool       `json:"stale,omitempty" bson:"stale,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+type FieldCounters struct {
+	Answered int64    `json:"answered" bson:"answered"`
+	Count    int64    `json:"count,omitempty" bson:"count,omitempty"` // values in Sum (rating) or LenSum (text)
+	Sum      float64  `json:"sum,omitempty" bson:"sum,omitempty"`
+	SumSq    float64  `json:"sumSq,omitempty" bson:"sumSq,omitempty"`
+	Min      *float64 `json:"min,omitempty" bson:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty" bson:"max,omitempty"`
+	LenSum   int64    `json:"lenSum,omitempty" bson:"lenSum,omitempty"` // text answers, in code points
+	LenMax   int64    `json:"lenMax,omitempty" bson:"lenMax,omitempty"`
+	// Options tallies selected options (choice) or distinct values (rating).
+	Options map[string]int64 `json:"options,omitempty" bson:"options,omitempty"`
+}
@@ -0,0 +1,25 @@
+// Data model for respondent activity on public forms, used for funnels.
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Form event types, in funnel order.
+const (
+	EventView  = "view"
+	EventStart = "start"
+	EventField = "field" // a respondent reached FieldID
+)
+
+type FormEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	FormID    primitive.ObjectID `json:"formId" bson:"formId"`
+	SessionID string             `json:"sessionId,omitempty" bson:"sessionId,omitempty"`
+	Type      string             `json:"type" bson:"type"`
+	FieldID   string             `json:"fieldId,omitempty" bson:"fieldId,omitempty"`
+	At        time.Time          `json:"at" bson:"at"`
+}
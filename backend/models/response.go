@@ -13,4 +13,10 @@ type Response struct {
 	FormID      primitive.ObjectID     `json:"formId" bson:"formId"`
 	Answers     map[string]interface{} `json:"answers" bson:"answers"`
 	SubmittedAt time.Time              `json:"submittedAt" bson:"submittedAt"`
+	DeletedAt   *time.Time             `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	SearchText  string                 `json:"-" bson:"searchText,omitempty"`
+	SessionID   string                 `json:"sessionId,omitempty" bson:"sessionId,omitempty"`
+	// FormVersion is the form version the answers were validated against;
+	// responses stored before it was recorded have none.
+	FormVersion int64 `json:"formVersion,omitempty" bson:"formVersion,omitempty"`
 }
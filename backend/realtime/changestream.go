@@ -0,0 +1,179 @@
+// MongoDB change-stream notifier: events are inserted into a shared
+// collection and every instance watches it, resuming after interruptions.
+
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection holds relayed events; a TTL index on "at" keeps it small.
+const Collection = "realtime_events"
+
+const (
+	publishTimeout = 5 * time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// relayed is an event as stored in Collection. Data is JSON so payloads
+// decode the same on every instance regardless of their Go type.
+type relayed struct {
+	FormID string    `bson:"formId"`
+	Type   string    `bson:"type"`
+	Data   string    `bson:"data,omitempty"`
+	At     time.Time `bson:"at"`
+}
+
+// ChangeStream relays events through Collection. Publish inserts; Run
+// watches for inserts from any instance, including this one, and delivers
+// them to local subscribers.
+type ChangeStream struct {
+	*hub
+	col *mongo.Collection
+
+	mu    sync.Mutex
+	token bson.Raw // resume token of the last delivered event
+}
+
+// NewChangeStream checks that col supports change streams (a replica set or
+// sharded cluster) before returning the notifier. Call Run to start
+// delivering.
+func NewChangeStream(ctx context.Context, col *mongo.Collection) (*ChangeStream, error) {
+	cs, err := col.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		return nil, err
+	}
+	_ = cs.Close(ctx)
+	return &ChangeStream{hub: newHub(), col: col}, nil
+}
+
+func (n *ChangeStream) Publish(formID string, ev Event) error {
+	doc := relayed{FormID: formID, Type: ev.Type, At: time.Now()}
+	if ev.Data != nil {
+		b, err := json.Marshal(ev.Data)
+		if err != nil {
+			return err
+		}
+		doc.Data = string(b)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if _, err := n.col.InsertOne(ctx, doc); err != nil {
+		// Local subscribers still hear about it, unsequenced; other
+		// instances miss it.
+		ev.ID = 0
+		n.deliver(formID, ev)
+		return err
+	}
+	return nil
+}
+
+// Run watches Collection until ctx ends, reopening the stream with the last
+// resume token after errors so no relayed event is missed. When the token
+// has aged out of the oplog the stream restarts from now. The backoff starts
+// over after a watch that delivered events or outlived the current delay, so
+// only back-to-back failures wait long.
+func (n *ChangeStream) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		delivered, err := n.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if delivered > 0 || time.Since(started) > backoff {
+			backoff = time.Second
+		}
+		var se mongo.ServerError
+		if err == nil {
+			// Invalidated, e.g. the collection was dropped; a token from
+			// before that can't be resumed.
+			log.Printf("realtime: change stream invalidated, restarting")
+			n.setToken(nil)
+			n.reset()
+		} else if errors.As(err, &se) && se.HasErrorCode(changeStreamHistoryLost) {
+			log.Printf("realtime: resume token expired, restarting change stream: %v", err)
+			n.setToken(nil)
+			n.reset()
+		} else {
+			log.Printf("realtime: change stream interrupted, resuming in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// changeStreamHistoryLost is the server error for a resume token that is no
+// longer in the oplog.
+const changeStreamHistoryLost = 286
+
+// watch streams inserts until an error or ctx ends, returning how many it
+// delivered.
+func (n *ChangeStream) watch(ctx context.Context) (int, error) {
+	opts := options.ChangeStream()
+	if tok := n.resumeToken(); tok != nil {
+		opts.SetResumeAfter(tok)
+	}
+	cs, err := n.col.Watch(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+	}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cs.Close(context.Background())
+
+	delivered := 0
+	for cs.Next(ctx) {
+		var change struct {
+			ClusterTime primitive.Timestamp `bson:"clusterTime"`
+			Doc         relayed             `bson:"fullDocument"`
+		}
+		if err := cs.Decode(&change); err != nil {
+			log.Printf("realtime: skipping undecodable event: %v", err)
+		} else {
+			ev := Event{ID: eventID(change.ClusterTime), Type: change.Doc.Type}
+			if change.Doc.Data != "" {
+				ev.Data = json.RawMessage(change.Doc.Data)
+			}
+			n.deliver(change.Doc.FormID, ev)
+			delivered++
+		}
+		n.setToken(cs.ResumeToken())
+	}
+	return delivered, cs.Err()
+}
+
+// eventID packs an oplog timestamp into an event ID. Cluster time orders
+// every write to the collection, so IDs agree across instances.
+func eventID(ts primitive.Timestamp) uint64 {
+	return uint64(ts.T)<<32 | uint64(ts.I)
+}
+
+func (n *ChangeStream) resumeToken() bson.Raw {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.token
+}
+
+func (n *ChangeStream) setToken(tok bson.Raw) {
+	n.mu.Lock()
+	n.token = tok
+	n.mu.Unlock()
+}
@@ -0,0 +1,231 @@
+// Package realtime fans form events out to subscribers. The in-memory
+// notifier serves a single instance; the change-stream notifier relays
+// events through MongoDB so every instance's subscribers hear them.
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a single notification for a form. Data is event specific and
+// must be JSON-serialisable; events relayed between instances carry it as
+// json.RawMessage.
+//
+// ID increases monotonically per form and is the same on every instance;
+// zero means the event couldn't be sequenced and won't be replayed.
+type Event struct {
+	ID   uint64
+	Type string
+	Data interface{}
+}
+
+// Subscription is a live feed of a form's events.
+type Subscription struct {
+	C <-chan Event
+	// Replay holds the buffered events after the ID passed to Subscribe,
+	// oldest first. Resumed reports that nothing in between was lost; when
+	// false the subscriber must treat its state as stale.
+	Replay  []Event
+	Resumed bool
+	// Unsubscribe stops delivery and closes C. It is safe to call twice.
+	Unsubscribe func()
+}
+
+// Notifier publishes form events and delivers them to subscribers.
+type Notifier interface {
+	// Publish delivers ev to every subscriber of formID. It does not block
+	// on slow subscribers; a full subscriber buffer drops the event.
+	Publish(formID string, ev Event) error
+	// Subscribe registers for formID's events. A non-zero after asks for a
+	// replay of the buffered events that followed it.
+	Subscribe(formID string, after uint64) Subscription
+}
+
+const (
+	// subscriberBuffer is how many undelivered events a subscriber may queue.
+	subscriberBuffer = 8
+	// replayLimit and replayWindow bound each form's replay log.
+	replayLimit  = 256
+	replayWindow = 10 * time.Minute
+	sweepEvery   = time.Minute
+)
+
+type logged struct {
+	ev Event
+	at time.Time
+}
+
+// replayLog holds a form's recent events. Every event with an ID above
+// from is present in events.
+type replayLog struct {
+	from   uint64
+	last   uint64
+	events []logged
+}
+
+// trim drops events beyond the size limit or older than cutoff.
+func (l *replayLog) trim(cutoff time.Time) {
+	drop := 0
+	for drop < len(l.events) && (len(l.events)-drop > replayLimit || l.events[drop].at.Before(cutoff)) {
+		drop++
+	}
+	if drop > 0 {
+		l.from = l.events[drop-1].ev.ID
+		l.events = append(l.events[:0:0], l.events[drop:]...)
+	}
+}
+
+// hub is the local subscriber registry and replay log shared by both
+// notifiers.
+type hub struct {
+	mu sync.Mutex
+	// key = formId hex
+	subs map[string]map[chan Event]struct{}
+	logs map[string]*replayLog
+	// horizon is the ID from which this hub has seen every event; events at
+	// or below it may have been missed. covered is false until it is known.
+	horizon uint64
+	covered bool
+	swept   time.Time
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: map[string]map[chan Event]struct{}{},
+		logs: map[string]*replayLog{},
+	}
+}
+
+func (h *hub) Subscribe(formID string, after uint64) Subscription {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if _, ok := h.subs[formID]; !ok {
+		h.subs[formID] = make(map[chan Event]struct{})
+	}
+	h.subs[formID][ch] = struct{}{}
+	sub := Subscription{C: ch}
+	if after > 0 {
+		sub.Replay, sub.Resumed = h.replay(formID, after)
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	sub.Unsubscribe = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			if set, ok := h.subs[formID]; ok {
+				delete(set, ch)
+				if len(set) == 0 {
+					delete(h.subs, formID)
+				}
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return sub
+}
+
+// replay returns formID's logged events after the given ID and whether they
+// are complete. Must hold h.mu.
+func (h *hub) replay(formID string, after uint64) ([]Event, bool) {
+	if !h.covered || after < h.horizon {
+		return nil, false
+	}
+	l := h.logs[formID]
+	if l == nil {
+		// Nothing seen for this form since coverage began, so only an ID
+		// at the horizon itself is known to be current.
+		return nil, after == h.horizon
+	}
+	l.trim(time.Now().Add(-replayWindow))
+	if after < l.from || after > l.last {
+		// Trimmed past it, or an ID this hub never issued (e.g. from before
+		// a restart of the in-memory notifier).
+		return nil, false
+	}
+	var out []Event
+	for _, e := range l.events {
+		if e.ev.ID > after {
+			out = append(out, e.ev)
+		}
+	}
+	return out, true
+}
+
+// deliver logs ev and fans it out to formID's local subscribers without
+// blocking. Events must arrive in ID order.
+func (h *hub) deliver(formID string, ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ev.ID > 0 {
+		h.log(formID, ev)
+	}
+	for ch := range h.subs[formID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// log appends ev to formID's replay log. Must hold h.mu.
+func (h *hub) log(formID string, ev Event) {
+	now := time.Now()
+	if !h.covered {
+		h.horizon, h.covered = ev.ID-1, true
+	}
+	l := h.logs[formID]
+	if l == nil {
+		l = &replayLog{from: h.horizon}
+		h.logs[formID] = l
+	}
+	l.events = append(l.events, logged{ev: ev, at: now})
+	l.last = ev.ID
+	l.trim(now.Add(-replayWindow))
+
+	// Forms that went quiet keep their log header (so IDs stay checkable)
+	// but release the buffered events.
+	if now.Sub(h.swept) >= sweepEvery {
+		h.swept = now
+		for _, l := range h.logs {
+			l.trim(now.Add(-replayWindow))
+		}
+	}
+}
+
+// reset forgets coverage after events may have been missed, so no replay
+// claims to be complete.
+func (h *hub) reset() {
+	h.mu.Lock()
+	h.logs = map[string]*replayLog{}
+	h.covered = false
+	h.mu.Unlock()
+}
+
+// Memory is the in-process notifier for single-node deployments and dev.
+// IDs count up from 1 per form and restart with the process.
+type Memory struct {
+	*hub
+	seqMu sync.Mutex
+	seq   map[string]uint64
+}
+
+func NewMemory() *Memory {
+	m := &Memory{hub: newHub(), seq: map[string]uint64{}}
+	m.hub.horizon, m.hub.covered = 0, true
+	return m
+}
+
+func (m *Memory) Publish(formID string, ev Event) error {
+	// Sequencing and delivery happen under one lock so IDs reach the log
+	// in order.
+	m.seqMu.Lock()
+	defer m.seqMu.Unlock()
+	m.seq[formID]++
+	ev.ID = m.seq[formID]
+	m.deliver(formID, ev)
+	return nil
+}